@@ -0,0 +1,203 @@
+package gitbr
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// reachable returns the set of commits reachable from any of the given ones,
+// them included. It gives up with the error of ctx once it is done.
+func reachable(ctx context.Context, repo *git.Repository, from ...plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	seen := make(map[plumbing.Hash]bool)
+	pending := append([]plumbing.Hash(nil), from...)
+	for len(pending) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		h := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		commit, err := repo.CommitObject(h)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, commit.ParentHashes...)
+	}
+	return seen, nil
+}
+
+// commitsNotIn returns the commits reachable from tip but not from any of the
+// others, the "others..tip" range in git terms. It gives up with the error of
+// ctx once it is done.
+func commitsNotIn(ctx context.Context, repo *git.Repository, tip plumbing.Hash, others ...plumbing.Hash) ([]*object.Commit, error) {
+	excluded, err := reachable(ctx, repo, others...)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []*object.Commit
+	seen := make(map[plumbing.Hash]bool)
+	pending := []plumbing.Hash{tip}
+	for len(pending) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		h := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if seen[h] || excluded[h] {
+			continue
+		}
+		seen[h] = true
+		commit, err := repo.CommitObject(h)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commit)
+		pending = append(pending, commit.ParentHashes...)
+	}
+	return commits, nil
+}
+
+// Divergence counts the commits of one side not reachable from the other.
+type Divergence struct {
+	Ahead  int
+	Behind int
+}
+
+func (d Divergence) String() string {
+	if d.Ahead == 0 && d.Behind == 0 {
+		return "="
+	}
+	return fmt.Sprintf("+%d -%d", d.Ahead, d.Behind)
+}
+
+// divergence counts the commits the walk found only reachable from a (ahead)
+// and only reachable from b (behind), like git rev-list --count b...a.
+func (w *paintWalk) divergence() Divergence {
+	var d Divergence
+	for _, n := range w.nodes {
+		switch n.flags {
+		case fromA:
+			d.Ahead++
+		case fromB:
+			d.Behind++
+		}
+	}
+	return d
+}
+
+const (
+	fromA = 1 << iota
+	fromB
+	common = fromA | fromB
+)
+
+// paintWalk walks the history of two commits at once, newest first, flagging
+// every commit with the sides it is reachable from. It stops as soon as all
+// the pending commits are reachable from both, so only the commits that are
+// not shared plus the border are ever read.
+type paintWalk struct {
+	repo    *git.Repository
+	nodes   map[plumbing.Hash]*paintNode
+	pending paintQueue
+	// uncommon is the number of queued commits not reachable from both sides.
+	uncommon int
+	// base is the best common ancestor, the most recent one if there are
+	// several of them, nil if the histories are unrelated.
+	base *object.Commit
+}
+
+type paintNode struct {
+	commit *object.Commit
+	flags  int
+	queued int
+}
+
+// paint walks a and b until their common history, giving up with the error of
+// ctx once it is done.
+func paint(ctx context.Context, repo *git.Repository, a, b plumbing.Hash) (*paintWalk, error) {
+	w := &paintWalk{repo: repo, nodes: make(map[plumbing.Hash]*paintNode)}
+	if err := w.mark(a, fromA); err != nil {
+		return nil, err
+	}
+	if err := w.mark(b, fromB); err != nil {
+		return nil, err
+	}
+
+	for w.uncommon > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n := heap.Pop(&w.pending).(*paintNode)
+		n.queued--
+		if n.queued == 0 && n.flags != common {
+			w.uncommon--
+		}
+		if n.flags == common && w.base == nil {
+			w.base = n.commit
+		}
+		for _, p := range n.commit.ParentHashes {
+			if err := w.mark(p, n.flags); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if w.base == nil && w.pending.Len() > 0 {
+		// everything left is common, the most recent one is the base
+		w.base = w.pending[0].commit
+	}
+	return w, nil
+}
+
+// mark adds flags to a commit, queueing it again if they are new to it so
+// they get propagated to its parents.
+func (w *paintWalk) mark(h plumbing.Hash, flags int) error {
+	n, ok := w.nodes[h]
+	if !ok {
+		commit, err := w.repo.CommitObject(h)
+		if err != nil {
+			return err
+		}
+		n = &paintNode{commit: commit}
+		w.nodes[h] = n
+	}
+	if n.flags|flags == n.flags {
+		return nil
+	}
+
+	wasUncommon := n.queued > 0 && n.flags != common
+	n.flags |= flags
+	n.queued++
+	heap.Push(&w.pending, n)
+	if isUncommon := n.flags != common; isUncommon && !wasUncommon {
+		w.uncommon++
+	} else if !isUncommon && wasUncommon {
+		w.uncommon--
+	}
+	return nil
+}
+
+// paintQueue is a priority queue of commits, the most recent first.
+type paintQueue []*paintNode
+
+func (q paintQueue) Len() int { return len(q) }
+func (q paintQueue) Less(i, j int) bool {
+	return q[i].commit.Committer.When.After(q[j].commit.Committer.When)
+}
+func (q paintQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *paintQueue) Push(x interface{}) { *q = append(*q, x.(*paintNode)) }
+func (q *paintQueue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
@@ -0,0 +1,81 @@
+package gitbr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalk(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	base := r.commit("initial", "README", "hello\n")
+	r.branch("feature", base)
+	r.checkout("feature")
+	r.commit("one", "a.txt", "a\n")
+	r.commit("two", "b.txt", "b\n")
+	r.checkout("master")
+	master := r.commit("three", "c.txt", "c\n")
+
+	inv := NewInventory(r.repo)
+	brs := r.branches()
+	d, err := inv.Divergence(brs["feature"], brs["master"])
+	assert.NoError(err)
+	assert.Equal(Divergence{Ahead: 2, Behind: 1}, d)
+
+	w, err := inv.walk(context.Background(), master, master)
+	assert.NoError(err)
+	assert.Equal(Divergence{}, w.Divergence)
+	assert.Equal("=", w.String())
+	assert.Equal(master, w.base)
+
+	mb, err := inv.MergeBase(brs["feature"], brs["master"])
+	assert.NoError(err)
+	if assert.NotNil(mb) {
+		assert.Equal(base, mb.Hash)
+	}
+
+	r.branch("old", base)
+	brs = r.branches()
+	merged, err := inv.Merged(brs["old"], brs["feature"])
+	assert.NoError(err)
+	assert.True(merged)
+	merged, err = inv.Merged(brs["master"], brs["feature"])
+	assert.NoError(err)
+	assert.False(merged)
+}
+
+func TestWalkAfterMerge(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n"))
+	r.checkout("feature")
+	r.commit("one", "a.txt", "a\n")
+	r.checkout("master")
+	r.commit("two", "b.txt", "b\n")
+	r.checkout("feature")
+	three := r.commit("three", "c.txt", "c\n")
+	r.checkout("master")
+	merge := r.merge("merge feature", three)
+	r.checkout("feature")
+	feature := r.commit("four", "d.txt", "d\n")
+
+	pw, err := paint(context.Background(), r.repo, feature, merge)
+	assert.NoError(err)
+	assert.Equal(Divergence{Ahead: 1, Behind: 2}, pw.divergence())
+	if assert.NotNil(pw.base) {
+		assert.Equal(three, pw.base.Hash)
+	}
+
+	inv := NewInventory(r.repo)
+	mb, err := inv.MergeBase(r.branches()["feature"], r.branches()["master"])
+	assert.NoError(err)
+	if assert.NotNil(mb) {
+		assert.Equal(three, mb.Hash)
+	}
+}
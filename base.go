@@ -0,0 +1,30 @@
+package gitbr
+
+import (
+	"strings"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// commonBases are the usual names of the main branch, in order of preference.
+var commonBases = []string{"main", "master", "develop", "trunk"}
+
+// resolveBase returns the name of the branch to compare the others against.
+// In order it takes the given name, the branch pointed by
+// refs/remotes/origin/HEAD and finally the first existing common name. It
+// returns an empty string if none of them is a local branch.
+func resolveBase(repo *git.Repository, brs Branches, name string) string {
+	candidates := []string{name}
+	if ref, err := repo.Reference("refs/remotes/origin/HEAD", false); err == nil && ref.Type() == plumbing.SymbolicReference {
+		candidates = append(candidates, strings.TrimPrefix(ref.Target().String(), "refs/remotes/origin/"))
+	}
+	candidates = append(candidates, commonBases...)
+
+	for _, c := range candidates {
+		if _, ok := brs[c]; ok {
+			return c
+		}
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package gitbr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestResolveBase(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	h := r.commit("initial", "README", "hello\n")
+	r.branch("develop", h)
+	r.branch("trunk", h)
+
+	assert.Equal("master", resolveBase(r.repo, r.branches(), ""))
+	assert.Equal("trunk", resolveBase(r.repo, r.branches(), "trunk"))
+
+	remote := plumbing.NewHashReference("refs/remotes/origin/develop", h)
+	assert.NoError(r.repo.Storer.SetReference(remote))
+	head := plumbing.NewSymbolicReference("refs/remotes/origin/HEAD", remote.Name())
+	assert.NoError(r.repo.Storer.SetReference(head))
+	assert.Equal("develop", resolveBase(r.repo, r.branches(), ""))
+	assert.Equal("master", resolveBase(r.repo, r.branches(), "master"))
+	assert.Equal("develop", resolveBase(r.repo, r.branches(), "nope"))
+}
+
+func TestResolveBaseWithoutCandidates(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	h := r.commit("initial", "README", "hello\n")
+	r.branch("feature", h)
+	assert.NoError(deleteBranch(r.repo, r.branches()["master"]))
+
+	assert.Equal("", resolveBase(r.repo, r.branches(), ""))
+}
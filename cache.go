@@ -0,0 +1,235 @@
+package gitbr
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/src-d/go-billy.v2"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+)
+
+const (
+	// walkCacheSize is the number of pairs of commits whose ancestry is
+	// kept, in memory and in .git/gitbr/ancestry.
+	walkCacheSize = 4096
+	// diffCacheSize is the number of pairs of trees whose changes are kept.
+	diffCacheSize = 64
+)
+
+// lru is a map of a fixed size that evicts the least recently used entries
+// first. It is safe for concurrent use.
+type lru struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[interface{}]*list.Element
+}
+
+type lruEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+func newLRU(size int) *lru {
+	return &lru{size: size, order: list.New(), items: make(map[interface{}]*list.Element)}
+}
+
+func (c *lru) get(key interface{}) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+func (c *lru) add(key, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		e.Value.(*lruEntry).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key, value})
+	if c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.items, e.Value.(*lruEntry).key)
+	}
+}
+
+// entries returns the entries, the least recently used first.
+func (c *lru) entries() []lruEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var entries []lruEntry
+	for e := c.order.Back(); e != nil; e = e.Prev() {
+		entries = append(entries, *e.Value.(*lruEntry))
+	}
+	return entries
+}
+
+// hashPair is the key of what is computed from two commits or two trees. As
+// git objects never change, neither does the result.
+type hashPair struct {
+	a, b plumbing.Hash
+}
+
+// walk is what paint learns about two commits: how far apart they are and
+// their merge base, the zero hash if they are unrelated.
+type walk struct {
+	Divergence
+	base plumbing.Hash
+}
+
+// walkCache keeps the walks of the pairs of commits in memory and, for
+// repositories on disk, appended to .git/gitbr/ancestry so the next runs
+// start with them. The new lines are written by flush, all at once.
+type walkCache struct {
+	walks *lru
+
+	mu      sync.Mutex
+	fs      billy.Filesystem
+	pending []string
+}
+
+const walkCacheFile = "gitbr/ancestry"
+
+// newWalkCache returns a cache loaded from the repository one, if any. The
+// disk is best effort: when it fails the cache is only kept in memory.
+func newWalkCache(repo *git.Repository) *walkCache {
+	c := &walkCache{walks: newLRU(walkCacheSize)}
+	s, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return c
+	}
+	c.fs = s.Filesystem()
+	if err := c.load(); err != nil {
+		c.fs = nil
+	}
+	return c
+}
+
+// get returns the walk of a and b, also found from the one of b and a.
+func (c *walkCache) get(a, b plumbing.Hash) (walk, bool) {
+	if w, ok := c.walks.get(hashPair{a, b}); ok {
+		return w.(walk), true
+	}
+	if w, ok := c.walks.get(hashPair{b, a}); ok {
+		w := w.(walk)
+		w.Ahead, w.Behind = w.Behind, w.Ahead
+		return w, true
+	}
+	return walk{}, false
+}
+
+func (c *walkCache) add(a, b plumbing.Hash, w walk) {
+	c.walks.add(hashPair{a, b}, w)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fs != nil {
+		c.pending = append(c.pending, formatWalk(a, b, w))
+	}
+}
+
+// flush appends the walks added since the last flush to the cache file.
+func (c *walkCache) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fs == nil || len(c.pending) == 0 {
+		return
+	}
+	lines := strings.Join(c.pending, "")
+	c.pending = nil
+	f, err := c.fs.OpenFile(walkCacheFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		c.fs = nil
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte(lines)); err != nil {
+		c.fs = nil
+	}
+}
+
+// load reads the cache file, compacting it once it holds twice the entries
+// kept.
+func (c *walkCache) load() error {
+	f, err := c.fs.Open(walkCacheFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines++
+		if a, b, w, ok := parseWalk(scanner.Text()); ok {
+			c.walks.add(hashPair{a, b}, w)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if lines <= 2*walkCacheSize {
+		return nil
+	}
+
+	var buf strings.Builder
+	for _, e := range c.walks.entries() {
+		k := e.key.(hashPair)
+		buf.WriteString(formatWalk(k.a, k.b, e.value.(walk)))
+	}
+	out, err := c.fs.OpenFile(walkCacheFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = out.Write([]byte(buf.String()))
+	return err
+}
+
+// formatWalk returns the cache file line of a walk, "a b ahead behind base".
+func formatWalk(a, b plumbing.Hash, w walk) string {
+	return fmt.Sprintf("%s %s %d %d %s\n", a, b, w.Ahead, w.Behind, w.base)
+}
+
+func parseWalk(line string) (plumbing.Hash, plumbing.Hash, walk, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 5 || !isHash(fields[0]) || !isHash(fields[1]) || !isHash(fields[4]) {
+		return plumbing.ZeroHash, plumbing.ZeroHash, walk{}, false
+	}
+	ahead, err1 := strconv.Atoi(fields[2])
+	behind, err2 := strconv.Atoi(fields[3])
+	if err1 != nil || err2 != nil {
+		return plumbing.ZeroHash, plumbing.ZeroHash, walk{}, false
+	}
+	w := walk{Divergence{ahead, behind}, plumbing.NewHash(fields[4])}
+	return plumbing.NewHash(fields[0]), plumbing.NewHash(fields[1]), w, true
+}
+
+// isHash reports whether s is a full hex SHA-1, as plumbing.NewHash takes
+// anything.
+func isHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
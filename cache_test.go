@@ -0,0 +1,100 @@
+package gitbr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestLRU(t *testing.T) {
+	assert := assert.New(t)
+	c := newLRU(2)
+	c.add("a", 1)
+	c.add("b", 2)
+	_, ok := c.get("a")
+	assert.True(ok)
+	c.add("c", 3)
+
+	_, ok = c.get("b")
+	assert.False(ok, "the least recently used entry is evicted")
+	v, ok := c.get("a")
+	assert.True(ok)
+	assert.Equal(1, v)
+	assert.Len(c.entries(), 2)
+}
+
+func TestWalkCache(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n"))
+	r.checkout("feature")
+	r.commit("feature", "feature.txt", "feature\n")
+	r.checkout("master")
+	master := r.commit("master moves on", "master.txt", "master\n")
+	brs := r.branches()
+
+	inv := NewInventory(r.repo)
+	d, err := inv.Divergence(brs["feature"], brs["master"])
+	assert.NoError(err)
+	assert.Equal(Divergence{Ahead: 1, Behind: 1}, d)
+
+	// the walk is kept on disk for the next runs, whatever the order of the
+	// commits asked for
+	data, err := ioutil.ReadFile(filepath.Join(r.path, ".git", "gitbr", "ancestry"))
+	assert.NoError(err)
+	assert.Len(data, len(formatWalk(brs["feature"].Commit.Hash, master, walk{})))
+	w, ok := NewInventory(r.repo).walks.get(master, brs["feature"].Commit.Hash)
+	assert.True(ok)
+	assert.Equal(Divergence{Ahead: 1, Behind: 1}, w.Divergence)
+	mb, err := inv.MergeBase(brs["master"], brs["feature"])
+	assert.NoError(err)
+	assert.Equal(w.base, mb.Hash)
+
+	// what is cached is trusted, as the commits cannot change
+	fake := walk{Divergence{Ahead: 7, Behind: 3}, plumbing.ZeroHash}
+	inv.walks.add(brs["feature"].Commit.Hash, master, fake)
+	inv.walks.flush()
+	d, err = NewInventory(r.repo).Divergence(brs["master"], brs["feature"])
+	assert.NoError(err)
+	assert.Equal(Divergence{Ahead: 3, Behind: 7}, d)
+
+	// corrupt lines are skipped
+	h := master.String()
+	for _, line := range []string{
+		"zz" + h[2:] + " " + h + " 1 2 " + h,
+		h + " " + h[:39] + " 1 2 " + h,
+		h + " " + h + " 1 2 " + h[:39] + "g",
+		h + " " + h + " x 2 " + h,
+	} {
+		_, _, _, ok := parseWalk(line)
+		assert.False(ok, line)
+	}
+	_, _, _, ok = parseWalk(h + " " + h + " 1 2 " + plumbing.ZeroHash.String())
+	assert.True(ok)
+}
+
+func TestDiffCache(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n"))
+	r.checkout("feature")
+	r.commit("feature", "feature.txt", "feature\n")
+	r.checkout("master")
+	brs := r.branches()
+
+	inv := NewInventory(r.repo)
+	changes, _, err := inv.Changes(brs["master"], brs["feature"], true)
+	assert.NoError(err)
+	again, _, err := inv.Changes(brs["master"], brs["feature"], true)
+	assert.NoError(err)
+	if assert.Len(again, 1) {
+		assert.True(changes[0] == again[0], "the changes come from the cache")
+	}
+}
@@ -0,0 +1,297 @@
+package gitbr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+)
+
+// checkoutPlan describes what switching the worktree to a branch would do to
+// the uncommitted changes it currently holds.
+type checkoutPlan struct {
+	target *Branch
+	// dirty are the tracked files with staged or unstaged changes.
+	dirty []string
+	// staged are the dirty files with staged changes, which are carried
+	// over unstaged.
+	staged []string
+	// conflicts are the dirty or untracked files the switch would overwrite.
+	conflicts []string
+}
+
+// clean reports whether the switch can be done without touching any change.
+func (p *checkoutPlan) clean() bool {
+	return len(p.dirty) == 0 && len(p.conflicts) == 0
+}
+
+// canCarry reports whether the uncommitted changes can be kept as they are in
+// the worktree after the switch.
+func (p *checkoutPlan) canCarry() bool {
+	return len(p.conflicts) == 0
+}
+
+func (p *checkoutPlan) String() string {
+	if p.clean() {
+		return "nothing to carry over"
+	}
+	if !p.canCarry() {
+		return fmt.Sprintf("%d files block the switch: %s", len(p.conflicts), fileList(p.conflicts))
+	}
+	return fmt.Sprintf("%d files with uncommitted changes: %s", len(p.dirty), fileList(p.dirty))
+}
+
+func fileList(files []string) string {
+	if len(files) > 3 {
+		return strings.Join(files[0:3], ", ") + ", ..."
+	}
+	return strings.Join(files, ", ")
+}
+
+// planCheckout inspects the worktree status and finds out which uncommitted
+// changes would be lost by switching to br.
+func planCheckout(repo *git.Repository, br *Branch) (*checkoutPlan, error) {
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	status, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &checkoutPlan{target: br}
+	if status.IsClean() {
+		return plan, nil
+	}
+
+	changed, err := changedFromHead(repo, br)
+	if err != nil {
+		return nil, err
+	}
+
+	for file, st := range status {
+		if st.Staging == git.Untracked && st.Worktree == git.Untracked {
+			// untracked files survive the switch unless the branch tracks them
+			if _, err := br.Tree.FindEntry(file); err == nil {
+				plan.conflicts = append(plan.conflicts, file)
+			}
+			continue
+		}
+		if st.Staging == git.Unmodified && st.Worktree == git.Unmodified {
+			continue
+		}
+		plan.dirty = append(plan.dirty, file)
+		if st.Staging != git.Unmodified {
+			plan.staged = append(plan.staged, file)
+		}
+		if changed[file] {
+			plan.conflicts = append(plan.conflicts, file)
+		}
+	}
+
+	sort.Strings(plan.dirty)
+	sort.Strings(plan.staged)
+	sort.Strings(plan.conflicts)
+	return plan, nil
+}
+
+// changedFromHead returns the set of files that differ between HEAD and br.
+func changedFromHead(repo *git.Repository, br *Branch) (map[string]bool, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTree(tree, br.Tree)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool, len(changes))
+	for _, c := range changes {
+		changed[c.From.Name] = true
+		changed[c.To.Name] = true
+	}
+	delete(changed, "")
+	return changed, nil
+}
+
+// checkout switches the worktree to the plan target, detaching HEAD for a
+// tag. Changes that do not conflict with the target are carried over as
+// unstaged modifications. The worktree is inspected again first, and the
+// switch refused if its changes are not the ones of the plan anymore.
+func checkout(repo *git.Repository, path string, plan *checkoutPlan) error {
+	if !plan.canCarry() {
+		return fmt.Errorf("cannot switch to %s, %s", plan.target.Name, plan)
+	}
+	now, err := planCheckout(repo, plan.target)
+	if err != nil {
+		return err
+	}
+	if !now.canCarry() || !sameFiles(now.dirty, plan.dirty) {
+		return fmt.Errorf("the uncommitted changes changed since the switch to %s was planned, %s", plan.target.Name, now)
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	saved, err := saveFiles(path, plan.dirty)
+	if err != nil {
+		return err
+	}
+	backup, err := backupFiles(repo, saved)
+	if err != nil {
+		return err
+	}
+
+	// forcing is safe here: every change it would discard has been saved,
+	// and they are put back even if the checkout fails halfway
+	opts := &git.CheckoutOptions{Branch: plan.target.Ref, Force: true}
+	if plan.target.isTag() {
+		// tags are checked out detached, at the commit they point to
+		opts = &git.CheckoutOptions{Hash: plan.target.Commit.Hash, Force: true}
+	}
+	err = w.Checkout(opts)
+	if rerr := restoreFiles(path, saved); rerr != nil {
+		return fmt.Errorf("%v, your uncommitted changes are kept in %s", rerr, backup)
+	}
+	if backup != "" {
+		os.RemoveAll(backup)
+	}
+	return err
+}
+
+func sameFiles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// carryDir is where the changes carried over are backed up during a switch,
+// in the git directory.
+const carryDir = "gitbr/carry"
+
+// backupFiles writes the saved files to carryDir, the changed ones under
+// files and the names of the deleted ones in a deleted file, so they are not
+// lost if the switch fails and they cannot be restored. It returns the
+// backup directory, empty if there is nothing to back up.
+func backupFiles(repo *git.Repository, saved []savedFile) (string, error) {
+	if len(saved) == 0 {
+		return "", nil
+	}
+	var dir string
+	if s, ok := repo.Storer.(*filesystem.Storage); ok {
+		dir = filepath.Join(s.Filesystem().Base(), filepath.FromSlash(carryDir))
+	} else {
+		tmp, err := ioutil.TempDir("", "git-br")
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(tmp, "carry")
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return "", fmt.Errorf("a failed switch left uncommitted changes in %s, restore or remove them first", dir)
+	}
+
+	var deleted []string
+	for _, f := range saved {
+		if f.deleted {
+			deleted = append(deleted, f.name)
+			continue
+		}
+		path := filepath.Join(dir, "files", f.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(path, f.content, f.mode); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "deleted"), []byte(strings.Join(deleted, "\n")), 0644); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// stashAndCheckout stashes every uncommitted change and then switches the
+// worktree to the plan target.
+func stashAndCheckout(repo *git.Repository, path string, plan *checkoutPlan) error {
+	msg := fmt.Sprintf("git-br: before switching to %s", plan.target.Name)
+	if _, err := runGit(path, "stash", "push", "--include-untracked", "-m", msg); err != nil {
+		return err
+	}
+
+	return checkout(repo, path, &checkoutPlan{target: plan.target})
+}
+
+// savedFile holds the worktree content of a file.
+type savedFile struct {
+	name    string
+	deleted bool
+	mode    os.FileMode
+	content []byte
+}
+
+func saveFiles(dir string, files []string) ([]savedFile, error) {
+	var saved []savedFile
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		fi, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			saved = append(saved, savedFile{name: name, deleted: true})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		saved = append(saved, savedFile{name: name, mode: fi.Mode(), content: content})
+	}
+	return saved, nil
+}
+
+func restoreFiles(dir string, saved []savedFile) error {
+	for _, f := range saved {
+		path := filepath.Join(dir, f.name)
+		if f.deleted {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, f.content, f.mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
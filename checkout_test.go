@@ -0,0 +1,124 @@
+package gitbr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanCheckoutClean(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	h := r.commit("initial", "README", "hello\n")
+	r.branch("feature", h)
+
+	plan, err := planCheckout(r.repo, r.branches()["feature"])
+	assert.NoError(err)
+	assert.True(plan.clean())
+}
+
+func TestCheckoutCarriesNonConflictingChanges(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n", "main.go", "package main\n"))
+	r.checkout("feature")
+	r.commit("feature", "feature.go", "package main\n")
+	r.checkout("master")
+	r.write("README", "hello world\n")
+
+	brs := r.branches()
+	plan, err := planCheckout(r.repo, brs["feature"])
+	assert.NoError(err)
+	assert.False(plan.clean())
+	assert.True(plan.canCarry())
+	assert.Equal([]string{"README"}, plan.dirty)
+
+	assert.NoError(checkout(r.repo, r.path, plan))
+	assert.Equal("hello world\n", r.read("README"))
+	assert.Equal("package main\n", r.read("feature.go"))
+	head, err := r.repo.Head()
+	assert.NoError(err)
+	assert.Equal(brs["feature"].Ref, head.Name())
+}
+
+func TestCheckoutRefusesConflictingChanges(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n"))
+	r.checkout("feature")
+	r.commit("feature", "README", "hello feature\n")
+	r.checkout("master")
+	r.write("README", "hello world\n")
+	r.write("notes.txt", "untracked\n")
+
+	plan, err := planCheckout(r.repo, r.branches()["feature"])
+	assert.NoError(err)
+	assert.False(plan.canCarry())
+	assert.Equal([]string{"README"}, plan.conflicts)
+
+	assert.Error(checkout(r.repo, r.path, plan))
+	assert.Equal("hello world\n", r.read("README"))
+}
+
+func TestCheckoutBacksUpCarriedChanges(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n", "main.go", "package main\n"))
+	r.write("README", "hello world\n")
+	_, err := r.w.Add("README")
+	assert.NoError(err)
+	r.write("main.go", "package main\n\nfunc main() {}\n")
+
+	plan, err := planCheckout(r.repo, r.branches()["feature"])
+	assert.NoError(err)
+	assert.Equal([]string{"README", "main.go"}, plan.dirty)
+	assert.Equal([]string{"README"}, plan.staged)
+
+	// a backup left by a failed switch blocks the next one
+	backup := filepath.Join(r.path, ".git", "gitbr", "carry")
+	assert.NoError(os.MkdirAll(backup, 0755))
+	assert.Error(checkout(r.repo, r.path, plan))
+	assert.Equal("hello world\n", r.read("README"))
+
+	assert.NoError(os.RemoveAll(backup))
+	assert.NoError(checkout(r.repo, r.path, plan))
+	assert.Equal("hello world\n", r.read("README"))
+	_, err = os.Stat(backup)
+	assert.True(os.IsNotExist(err))
+}
+
+func TestCheckoutRefusesChangesMadeAfterThePlan(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n", "main.go", "package main\n"))
+	r.checkout("feature")
+	r.commit("feature", "main.go", "package feature\n")
+	r.checkout("master")
+	r.write("README", "hello world\n")
+
+	brs := r.branches()
+	plan, err := planCheckout(r.repo, brs["feature"])
+	assert.NoError(err)
+	assert.True(plan.canCarry())
+
+	// edited while the question was shown, the switch would overwrite it
+	r.write("main.go", "package main\n\nfunc main() {}\n")
+	assert.Error(checkout(r.repo, r.path, plan))
+	assert.Equal("package main\n\nfunc main() {}\n", r.read("main.go"))
+	assert.Equal("hello world\n", r.read("README"))
+	head, err := r.repo.Head()
+	assert.NoError(err)
+	assert.Equal(brs["master"].Ref, head.Name())
+}
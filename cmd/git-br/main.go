@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
@@ -8,11 +9,19 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "list" {
+		list(os.Args[2:])
+		return
+	}
+
+	base := flag.String("base", "", "branch to compare the others against")
+	flag.Parse()
+
 	path := "."
-	if len(os.Args) > 1 {
-		path = os.Args[1]
+	if flag.NArg() > 0 {
+		path = flag.Arg(0)
 	}
-	uiRunner, err := gitbr.Open(path)
+	uiRunner, err := gitbr.Open(path, gitbr.WithBase(*base))
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		os.Exit(1)
@@ -26,3 +35,24 @@ func main() {
 
 	println("Goodbye!")
 }
+
+// list prints the branches without running the UI, for scripts.
+func list(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	base := fs.String("base", "", "branch to check whether the others are merged into")
+	format := fs.String("format", "table", "output format: table, tsv or json")
+	tmpl := fs.String("template", "", "Go text/template to print every branch with, e.g. '{{.Name}} {{.SHA}}'")
+	if err := fs.Parse(args); err != nil {
+		os.Exit(2)
+	}
+
+	path := "."
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	err := gitbr.List(os.Stdout, path, gitbr.WithBase(*base), gitbr.WithFormat(*format), gitbr.WithTemplate(*tmpl))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,287 @@
+package gitbr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/marcusolsson/tui-go"
+	runewidth "github.com/mattn/go-runewidth"
+	git "gopkg.in/src-d/go-git.v4"
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
+)
+
+// settings are the user preferences. They are read, from lowest to highest
+// precedence, from the defaults, the user config file and the [gitbr]
+// section of the repository git config. Command line flags go last.
+type settings struct {
+	base string
+	sort sortMode
+	// protected are the patterns of the branches that cannot be deleted,
+	// renamed or pruned, defaultProtected if none is set.
+	protected   []string
+	authorWidth int
+	nameWidth   int
+	// maxChanges is the number of changed files listed, 0 for all of them.
+	maxChanges int
+	// live refreshes the branches when their refs change.
+	live bool
+	// theme maps tui style names, like diff.added, to their colors.
+	theme map[string]tui.Style
+	// keys maps the actions to the keys bound to them.
+	keys map[string]string
+}
+
+const (
+	defaultAuthorWidth = 16
+	defaultNameWidth   = 32
+)
+
+// defaultTheme are the styles used when the config sets none.
+var defaultTheme = map[string]tui.Style{
+	"table.cell.selected":      {Bg: tui.ColorGreen, Fg: tui.ColorWhite},
+	"list.item":                {Bg: tui.ColorBlack, Fg: tui.ColorWhite},
+	"list.item.selected":       {Bg: tui.ColorGreen, Fg: tui.ColorWhite},
+	"list.item.match":          {Bg: tui.ColorBlack, Fg: tui.ColorYellow},
+	"list.item.selected.match": {Bg: tui.ColorGreen, Fg: tui.ColorYellow},
+	"list.item.protected":      {Bg: tui.ColorBlack, Fg: tui.ColorCyan},
+	"diff.file":                {Bg: tui.ColorBlack, Fg: tui.ColorWhite},
+	"diff.hunk":                {Bg: tui.ColorBlack, Fg: tui.ColorCyan},
+	"diff.added":               {Bg: tui.ColorBlack, Fg: tui.ColorGreen},
+	"diff.deleted":             {Bg: tui.ColorBlack, Fg: tui.ColorRed},
+	"diff.binary":              {Bg: tui.ColorBlack, Fg: tui.ColorYellow},
+}
+
+var colors = map[string]tui.Color{
+	"default": tui.ColorDefault,
+	"black":   tui.ColorBlack,
+	"white":   tui.ColorWhite,
+	"red":     tui.ColorRed,
+	"green":   tui.ColorGreen,
+	"blue":    tui.ColorBlue,
+	"cyan":    tui.ColorCyan,
+	"magenta": tui.ColorMagenta,
+	"yellow":  tui.ColorYellow,
+}
+
+var defaultProtected = []string{"main", "master", "develop", "trunk"}
+
+func defaultSettings() *settings {
+	s := &settings{
+		sort:        byAuthorDate,
+		authorWidth: defaultAuthorWidth,
+		nameWidth:   defaultNameWidth,
+		maxChanges:  30,
+		live:        true,
+		theme:       make(map[string]tui.Style),
+		keys:        make(map[string]string),
+	}
+	for name, style := range defaultTheme {
+		s.theme[name] = style
+	}
+	for _, a := range actions {
+		s.keys[a.name] = a.key
+	}
+	return s
+}
+
+// configPath returns where the user config file is,
+// $XDG_CONFIG_HOME/git-br/config or ~/.config/git-br/config.
+func configPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "git-br", "config")
+}
+
+// loadSettings reads the settings from the user config file, if any, and the
+// repository git config.
+func loadSettings(repo *git.Repository) (*settings, error) {
+	s := defaultSettings()
+
+	path := configPath()
+	f, err := os.Open(path)
+	switch {
+	case err == nil:
+		raw := format.New()
+		err := format.NewDecoder(f).Decode(raw)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		if err := s.apply(raw); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(cfg.Raw); err != nil {
+		return nil, fmt.Errorf("git config: %s", err)
+	}
+	return s, nil
+}
+
+// apply overrides the settings with the gitbr section of raw. Protected
+// patterns add up instead.
+func (s *settings) apply(raw *format.Config) error {
+	section := raw.Section(gitbrSection)
+	for _, o := range section.Options {
+		if err := s.set(o.Key, o.Value); err != nil {
+			return err
+		}
+	}
+	for _, sub := range section.Subsections {
+		var set func(name, value string) error
+		switch strings.ToLower(sub.Name) {
+		case "theme":
+			set = s.setStyle
+		case "keys":
+			set = s.setKey
+		default:
+			return fmt.Errorf("unknown section gitbr.%s, use gitbr.theme or gitbr.keys", sub.Name)
+		}
+		for _, o := range sub.Options {
+			if err := set(o.Key, o.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return s.checkKeys()
+}
+
+func (s *settings) set(key, value string) error {
+	var err error
+	switch strings.ToLower(key) {
+	case "base":
+		s.base = value
+	case "sort":
+		s.sort, err = parseSortMode(value)
+	case "protected":
+		s.protected = append(s.protected, value)
+	case "authorwidth":
+		s.authorWidth, err = parseWidth(value)
+	case "namewidth":
+		s.nameWidth, err = parseWidth(value)
+	case "maxchanges":
+		s.maxChanges, err = strconv.Atoi(value)
+		if err == nil && s.maxChanges < 0 {
+			err = fmt.Errorf("it cannot be negative")
+		}
+	case "live":
+		s.live, err = parseBool(value)
+	default:
+		return fmt.Errorf("unknown setting gitbr.%s", key)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid gitbr.%s %q: %s", key, value, err)
+	}
+	return nil
+}
+
+func parseSortMode(value string) (sortMode, error) {
+	for _, mode := range sortModes {
+		if string(mode) == strings.ToLower(value) {
+			return mode, nil
+		}
+	}
+	var names []string
+	for _, mode := range sortModes {
+		names = append(names, string(mode))
+	}
+	return "", fmt.Errorf("use one of %s", strings.Join(names, ", "))
+}
+
+// protectedPatterns returns the patterns of the protected branches.
+func (s *settings) protectedPatterns() []string {
+	if len(s.protected) == 0 {
+		return defaultProtected
+	}
+	return s.protected
+}
+
+// parseBool parses a boolean the way git config does.
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1", "":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("use true or false")
+}
+
+// parseWidth parses a column width, which must leave room for the cutTail of
+// the cut values.
+func parseWidth(value string) (int, error) {
+	w, err := strconv.Atoi(value)
+	if min := runewidth.StringWidth(cutTail) + 1; err == nil && w < min {
+		err = fmt.Errorf("it must be at least %d", min)
+	}
+	return w, err
+}
+
+// setStyle sets a style from a "fg" or "fg,bg" color value. Git config
+// names cannot have dots, so the style diff.added is set as diff-added.
+func (s *settings) setStyle(key, value string) error {
+	name := strings.Replace(strings.ToLower(key), "-", ".", -1)
+	if _, ok := defaultTheme[name]; !ok {
+		return fmt.Errorf("unknown style gitbr.theme.%s", key)
+	}
+	style := s.theme[name]
+	parts := strings.Split(value, ",")
+	if len(parts) > 2 {
+		return fmt.Errorf("invalid gitbr.theme.%s %q: use a foreground color and an optional background one, like white,green", key, value)
+	}
+	for i, part := range parts {
+		c, ok := colors[strings.ToLower(strings.TrimSpace(part))]
+		if !ok {
+			return fmt.Errorf("invalid gitbr.theme.%s %q: unknown color %q", key, value, part)
+		}
+		if i == 0 {
+			style.Fg = c
+		} else {
+			style.Bg = c
+		}
+	}
+	s.theme[name] = style
+	return nil
+}
+
+func (s *settings) setKey(action, key string) error {
+	action = strings.ToLower(action)
+	if _, ok := findAction(action); !ok {
+		return fmt.Errorf("unknown action gitbr.keys.%s", action)
+	}
+	switch {
+	case key == "" || keyName(key) == "esc":
+		return fmt.Errorf("invalid gitbr.keys.%s %q: esc is reserved to go back", action, key)
+	case len(key) == 1 && key[0] >= '0' && key[0] <= '9':
+		return fmt.Errorf("invalid gitbr.keys.%s %q: digits are counts, like the 5 of 5j", action, key)
+	}
+	s.keys[action] = key
+	return nil
+}
+
+// checkKeys fails if a key is bound to more than one action.
+func (s *settings) checkKeys() error {
+	bound := make(map[string]string)
+	for action, key := range s.keys {
+		k := keyName(key)
+		if other, ok := bound[k]; ok {
+			if other > action {
+				action, other = other, action
+			}
+			return fmt.Errorf("key %s is bound to both %s and %s", key, other, action)
+		}
+		bound[k] = action
+	}
+	return nil
+}
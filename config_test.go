@@ -0,0 +1,90 @@
+package gitbr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marcusolsson/tui-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSettings(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	home := noUserConfig(t)
+
+	s, err := loadSettings(r.repo)
+	assert.NoError(err)
+	assert.Equal(defaultSettings(), s)
+
+	assert.NoError(os.MkdirAll(filepath.Join(home, "git-br"), 0755))
+	assert.NoError(ioutil.WriteFile(configPath(), []byte(`[gitbr]
+	base = develop
+	sort = name
+	protected = release/*
+	nameWidth = 20
+	live = no
+[gitbr "theme"]
+	diff-added = blue
+	list-item-selected = white,magenta
+[gitbr "keys"]
+	quit = x
+`), 0644))
+
+	cfg, err := r.repo.Config()
+	assert.NoError(err)
+	section := cfg.Raw.Section(gitbrSection)
+	section.SetOption("sort", "author")
+	section.AddOption("protected", "main")
+	assert.NoError(r.repo.Storer.SetConfig(cfg))
+
+	s, err = loadSettings(r.repo)
+	assert.NoError(err)
+	assert.Equal("develop", s.base)
+	assert.Equal(byAuthor, s.sort)
+	assert.Equal([]string{"release/*", "main"}, s.protectedPatterns())
+	assert.Equal(defaultProtected, defaultSettings().protectedPatterns())
+	assert.Equal(20, s.nameWidth)
+	assert.False(s.live)
+	assert.Equal(defaultAuthorWidth, s.authorWidth)
+	assert.Equal(tui.Style{Bg: tui.ColorBlack, Fg: tui.ColorBlue}, s.theme["diff.added"])
+	assert.Equal(tui.Style{Bg: tui.ColorMagenta, Fg: tui.ColorWhite}, s.theme["list.item.selected"])
+	assert.Equal("x", s.keys["quit"])
+	assert.Equal("d", s.keys["delete"])
+
+	// the base of the repository overrides the one of the user
+	section.SetOption("base", "trunk")
+	assert.NoError(r.repo.Storer.SetConfig(cfg))
+	s, err = loadSettings(r.repo)
+	assert.NoError(err)
+	assert.Equal("trunk", s.base)
+}
+
+func TestSettingsErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := map[string]func(s *settings) error{
+		"unknown setting gitbr.colour":                func(s *settings) error { return s.set("colour", "red") },
+		`invalid gitbr.sort "size"`:                   func(s *settings) error { return s.set("sort", "size") },
+		`invalid gitbr.nameWidth "1"`:                 func(s *settings) error { return s.set("nameWidth", "1") },
+		`invalid gitbr.live "maybe"`:                  func(s *settings) error { return s.set("live", "maybe") },
+		`invalid gitbr.maxChanges "-1"`:               func(s *settings) error { return s.set("maxChanges", "-1") },
+		"unknown style gitbr.theme.diff-moved":        func(s *settings) error { return s.setStyle("diff-moved", "red") },
+		`unknown color "pink"`:                        func(s *settings) error { return s.setStyle("diff-added", "pink") },
+		"unknown action gitbr.keys.push":              func(s *settings) error { return s.setKey("push", "P") },
+		"esc is reserved":                             func(s *settings) error { return s.setKey("quit", "Esc") },
+		"digits are counts":                           func(s *settings) error { return s.setKey("quit", "5") },
+		"key d is bound to both delete and quit":      func(s *settings) error { s.keys["quit"] = "d"; return s.checkKeys() },
+		"key Tab is bound to both focus and nextfile": func(s *settings) error { s.keys["nextfile"] = "Tab"; return s.checkKeys() },
+	}
+	for msg, f := range cases {
+		err := f(defaultSettings())
+		if assert.Error(err, msg) {
+			assert.Contains(err.Error(), msg)
+		}
+	}
+}
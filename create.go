@@ -0,0 +1,53 @@
+package gitbr
+
+import (
+	"fmt"
+	"strings"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// createBranch creates a branch pointing to the tip of from. With track, from
+// becomes its upstream.
+func createBranch(repo *git.Repository, name string, from *Branch, track bool) (*Branch, error) {
+	if err := checkRefName(name); err != nil {
+		return nil, err
+	}
+	ref := plumbing.ReferenceName("refs/heads/" + name)
+	if _, err := repo.Storer.Reference(ref); err == nil {
+		return nil, fmt.Errorf("branch %s already exists", name)
+	}
+
+	err := repo.Storer.SetReference(plumbing.NewHashReference(ref, from.Commit.Hash))
+	if err != nil {
+		return nil, err
+	}
+
+	if track {
+		remote, merge := ".", from.Ref
+		if from.Remote != "" {
+			remote = from.Remote
+			merge = plumbing.ReferenceName("refs/heads/" + strings.TrimPrefix(from.Name, from.Remote+"/"))
+		}
+		if err := setUpstream(repo, name, remote, merge); err != nil {
+			return nil, err
+		}
+	}
+
+	created := *from
+	created.Name = name
+	created.Ref = ref
+	created.Remote = ""
+	created.Annotation = nil
+	created.Head = false
+	// the divergences are the ones of from, computed again by Compare
+	created.Base, created.Upstream = nil, nil
+	return &created, nil
+}
+
+// createTracking creates a local branch named after the remote-tracking
+// branch remote, with the latter as its upstream.
+func createTracking(repo *git.Repository, remote *Branch) (*Branch, error) {
+	return createBranch(repo, strings.TrimPrefix(remote.Name, remote.Remote+"/"), remote, true)
+}
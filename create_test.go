@@ -0,0 +1,70 @@
+package gitbr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestCreateTracking(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	h := r.commit("initial", "README", "hello\n")
+	remote := plumbing.NewHashReference("refs/remotes/origin/feature/x", h)
+	assert.NoError(r.repo.Storer.SetReference(remote))
+	head := plumbing.NewSymbolicReference("refs/remotes/origin/HEAD", "refs/remotes/origin/feature/x")
+	assert.NoError(r.repo.Storer.SetReference(head))
+
+	remotes, err := extractRemotes(r.repo)
+	assert.NoError(err)
+	assert.Len(remotes, 1)
+	rbr := remotes["origin/feature/x"]
+	if !assert.NotNil(rbr) {
+		return
+	}
+	assert.Equal("origin", rbr.Remote)
+
+	local, err := createTracking(r.repo, rbr)
+	assert.NoError(err)
+	assert.Equal("feature/x", local.Name)
+	assert.Equal("", local.Remote)
+	up, ok := upstream(r.repo, "feature/x")
+	assert.True(ok)
+	assert.Equal(plumbing.ReferenceName("refs/remotes/origin/feature/x"), up)
+	assert.Contains(r.branches(), "feature/x")
+
+	_, err = createTracking(r.repo, rbr)
+	assert.Error(err)
+}
+
+func TestCreateBranch(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.commit("initial", "README", "hello\n")
+	master := r.branches()["master"]
+	master.Upstream = &Divergence{Ahead: 1}
+
+	created, err := createBranch(r.repo, "feature", master, false)
+	assert.NoError(err)
+	assert.Equal(master.Commit.Hash, created.Commit.Hash)
+	assert.Nil(created.Upstream)
+	_, ok := upstream(r.repo, "feature")
+	assert.False(ok)
+
+	_, err = createBranch(r.repo, "tracking", master, true)
+	assert.NoError(err)
+	up, ok := upstream(r.repo, "tracking")
+	assert.True(ok)
+	assert.Equal(master.Ref, up)
+
+	_, err = createBranch(r.repo, "feature", master, false)
+	assert.Error(err)
+	_, err = createBranch(r.repo, "no spaces", master, false)
+	assert.Error(err)
+	assert.Len(r.branches(), 3)
+}
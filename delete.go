@@ -0,0 +1,114 @@
+package gitbr
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+)
+
+// deletePlan describes what deleting a branch would lose.
+type deletePlan struct {
+	target *Branch
+	// against are the names of the refs the branch was checked against.
+	against []string
+	// unmerged is the number of commits reachable only from the branch, -1
+	// if there is nothing to check it against.
+	unmerged int
+}
+
+// merged reports whether every commit of the branch survives the deletion.
+func (p *deletePlan) merged() bool {
+	return p.unmerged == 0
+}
+
+// planDelete checks whether the tip of br is reachable from the base branch
+// or from its upstream, counting the commits that would be lost otherwise.
+func planDelete(repo *git.Repository, brs Branches, br *Branch, baseName string) (*deletePlan, error) {
+	head, err := repo.Head()
+	if err == nil && head.Name() == br.Ref {
+		return nil, fmt.Errorf("cannot delete %s, it is checked out", br.Name)
+	}
+
+	plan := &deletePlan{target: br}
+	var others []plumbing.Hash
+	if base, ok := brs[baseName]; ok && base != br {
+		plan.against = append(plan.against, baseName)
+		others = append(others, base.Commit.Hash)
+	}
+	if h, ok := upstreamHash(repo, br.Name); ok {
+		ref, _ := upstream(repo, br.Name)
+		plan.against = append(plan.against, ref.Short())
+		others = append(others, h)
+	}
+
+	if len(others) == 0 {
+		plan.unmerged = -1
+		return plan, nil
+	}
+	unmerged, err := commitsNotIn(context.Background(), repo, br.Commit.Hash, others...)
+	if err != nil {
+		return nil, err
+	}
+	plan.unmerged = len(unmerged)
+	return plan, nil
+}
+
+// deleteBranch removes the branch ref and its branch.<name> config section.
+func deleteBranch(repo *git.Repository, br *Branch) error {
+	if err := removeRef(repo, br.Ref); err != nil {
+		return err
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	if !cfg.Raw.Section(branchSection).HasSubsection(br.Name) {
+		return nil
+	}
+	cfg.Raw.RemoveSubsection(branchSection, br.Name)
+	return repo.Storer.SetConfig(cfg)
+}
+
+// removeRef deletes a reference. A ref can be both loose and packed but go-git
+// only removes one of them at a time.
+func removeRef(repo *git.Repository, name plumbing.ReferenceName) error {
+	defer removeEmptyDirs(repo, name)
+	for i := 0; i < 2; i++ {
+		_, err := repo.Storer.Reference(name)
+		if err == plumbing.ErrReferenceNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := repo.Storer.RemoveReference(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeEmptyDirs removes the directories of a removed loose ref left empty,
+// like git does, so refs/heads/feature can be created once
+// refs/heads/feature/x is gone.
+func removeEmptyDirs(repo *git.Repository, name plumbing.ReferenceName) {
+	s, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return
+	}
+	fs := s.Filesystem()
+	for dir := path.Dir(string(name)); strings.Count(dir, "/") > 1; dir = path.Dir(dir) {
+		if files, err := fs.ReadDir(dir); err != nil || len(files) > 0 {
+			return
+		}
+		if err := fs.Remove(dir); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package gitbr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanDeleteMerged(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n"))
+	r.commit("second", "README", "hello world\n")
+
+	brs := r.branches()
+	plan, err := planDelete(r.repo, brs, brs["feature"], "master")
+	assert.NoError(err)
+	assert.True(plan.merged())
+	assert.Equal([]string{"master"}, plan.against)
+}
+
+func TestPlanDeleteUnmerged(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n"))
+	r.checkout("feature")
+	r.commit("one", "a.txt", "a\n")
+	r.commit("two", "b.txt", "b\n")
+	r.checkout("master")
+
+	brs := r.branches()
+	plan, err := planDelete(r.repo, brs, brs["feature"], "master")
+	assert.NoError(err)
+	assert.False(plan.merged())
+	assert.Equal(2, plan.unmerged)
+}
+
+func TestPlanDeleteCheckedOutErrors(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.commit("initial", "README", "hello\n")
+
+	brs := r.branches()
+	_, err := planDelete(r.repo, brs, brs["master"], "master")
+	assert.Error(err)
+}
+
+func TestDeleteBranch(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n"))
+	cfg, err := r.repo.Config()
+	assert.NoError(err)
+	cfg.Raw.Section(branchSection).Subsection("feature").SetOption("remote", "origin")
+	assert.NoError(r.repo.Storer.SetConfig(cfg))
+
+	assert.NoError(deleteBranch(r.repo, r.branches()["feature"]))
+	_, ok := r.branches()["feature"]
+	assert.False(ok)
+	cfg, err = r.repo.Config()
+	assert.NoError(err)
+	assert.False(cfg.Raw.Section(branchSection).HasSubsection("feature"))
+}
+
+func TestPlanDeleteWithoutBase(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n"))
+
+	brs := r.branches()
+	plan, err := planDelete(r.repo, brs, brs["feature"], "")
+	assert.NoError(err)
+	assert.False(plan.merged())
+	assert.Empty(plan.against)
+	assert.Equal(-1, plan.unmerged)
+}
@@ -0,0 +1,31 @@
+package gitbr
+
+import (
+	"context"
+	"fmt"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// diffFrom returns the tree the changes of br are computed from and a label
+// describing it. With threeDot it is the tree of the merge base of base and
+// br, like git diff base...br does, so the changes made in base since br
+// forked are left out. Otherwise it is the tree of base itself.
+func diffFrom(ctx context.Context, inv *Inventory, base, br *Branch, threeDot bool) (*object.Tree, string, error) {
+	if !threeDot {
+		return base.Tree, base.Name, nil
+	}
+
+	mb, err := inv.mergeBase(ctx, base, br)
+	if err != nil {
+		return nil, "", err
+	}
+	if mb == nil {
+		return base.Tree, base.Name + " (no common history)", nil
+	}
+	tree, err := mb.Tree()
+	if err != nil {
+		return nil, "", err
+	}
+	return tree, fmt.Sprintf("%s... (merge base %s)", base.Name, mb.Hash.String()[0:7]), nil
+}
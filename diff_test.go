@@ -0,0 +1,61 @@
+package gitbr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestDiffFrom(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n"))
+	r.checkout("feature")
+	r.commit("feature", "feature.txt", "feature\n")
+	r.checkout("master")
+	r.commit("master moves on", "master.txt", "master\n")
+
+	brs := r.branches()
+	from, _, err := diffFrom(context.Background(), NewInventory(r.repo), brs["master"], brs["feature"], true)
+	assert.NoError(err)
+	changes, err := object.DiffTree(from, brs["feature"].Tree)
+	assert.NoError(err)
+	if assert.Len(changes, 1) {
+		assert.Equal("feature.txt", changes[0].To.Name)
+	}
+
+	from, label, err := diffFrom(context.Background(), NewInventory(r.repo), brs["master"], brs["feature"], false)
+	assert.NoError(err)
+	assert.Equal("master", label)
+	changes, err = object.DiffTree(from, brs["feature"].Tree)
+	assert.NoError(err)
+	assert.Len(changes, 2)
+}
+
+func TestChangedFiles(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n", "old.txt", "old\n"))
+	r.checkout("feature")
+	if _, err := r.w.Remove("old.txt"); err != nil {
+		t.Fatal(err)
+	}
+	r.commit("feature", "README", "hello world\n", "new.txt", "new\n")
+	r.checkout("master")
+
+	brs := r.branches()
+	changes, err := object.DiffTree(brs["master"].Tree, brs["feature"].Tree)
+	assert.NoError(err)
+	sorted, lines := changedFiles(changes)
+	assert.Equal([]string{"A: new.txt", "D: old.txt", "M: README"}, lines)
+	if assert.Len(sorted, 3) {
+		assert.Equal("new.txt", sorted[0].To.Name)
+		assert.Equal("old.txt", sorted[1].From.Name)
+	}
+}
@@ -0,0 +1,207 @@
+package gitbr
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// diffDelay is how long a diff waits before being computed, so that moving
+// through the list only computes the one of the branch the cursor stops at.
+const diffDelay = 100 * time.Millisecond
+
+type jobKind int
+
+const (
+	// changesJob diffs a branch against the base branch.
+	changesJob jobKind = iota
+	// patchJob diffs a branch against the base branch, with the patch.
+	patchJob
+	// logJob lists the commits of a branch not in the base branch.
+	logJob
+	// fileJob renders the patch of a changed file.
+	fileJob
+	// commitJob renders the patch of a commit against its first parent.
+	commitJob
+	// stashJob renders the patch of a stash, its untracked files included.
+	stashJob
+)
+
+// diffJob is a diff to compute in the background. Branches are given by
+// name and commit as the objects are read from the differ repository.
+type diffJob struct {
+	kind     jobKind
+	base     string
+	baseHash plumbing.Hash
+	br       string
+	brHash   plumbing.Hash
+	threeDot bool
+	// change is the file of a fileJob, from an earlier result.
+	change *object.Change
+	// commit is the commit of a commitJob or a stashJob.
+	commit plumbing.Hash
+
+	ctx  context.Context
+	done func(*diffResult)
+}
+
+type diffResult struct {
+	changes object.Changes
+	label   string
+	commits []*object.Commit
+	lines   []patchLine
+	err     error
+}
+
+// differ computes diffs one at a time in a goroutine. Submitting a job
+// cancels the previous one, which never reports back. It reads the objects
+// from its own repository, as go-git ones are not safe for concurrent use.
+type differ struct {
+	inv   *Inventory
+	delay time.Duration
+
+	mu     sync.Mutex
+	next   *diffJob
+	cancel context.CancelFunc
+	wake   chan struct{}
+}
+
+func newDiffer(inv *Inventory) *differ {
+	d := &differ{inv: inv, delay: diffDelay, wake: make(chan struct{}, 1)}
+	go d.loop()
+	return d
+}
+
+// submit queues the job, cancelling the pending or running one. Its done
+// function is called from the differ goroutine unless it is cancelled.
+func (d *differ) submit(job diffJob) {
+	d.mu.Lock()
+	if d.cancel != nil {
+		d.cancel()
+	}
+	job.ctx, d.cancel = context.WithCancel(context.Background())
+	d.next = &job
+	d.mu.Unlock()
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// stop cancels the pending or running job.
+func (d *differ) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.next = nil
+}
+
+func (d *differ) loop() {
+	for range d.wake {
+		d.mu.Lock()
+		job := d.next
+		d.next = nil
+		d.mu.Unlock()
+		if job == nil {
+			continue
+		}
+
+		select {
+		case <-time.After(d.delay):
+		case <-job.ctx.Done():
+			continue
+		}
+		res := d.run(job)
+		if job.ctx.Err() == nil {
+			job.done(res)
+		}
+	}
+}
+
+// run computes the job, giving up as soon as it is cancelled: the walks
+// check it as they go and it is checked again between the steps.
+func (d *differ) run(job *diffJob) *diffResult {
+	defer d.inv.walks.flush()
+	res := &diffResult{}
+	switch job.kind {
+	case fileJob:
+		res.lines, res.err = d.patch(job.ctx, object.Changes{job.change})
+		return res
+	case commitJob, stashJob:
+		c, err := d.inv.repo.CommitObject(job.commit)
+		if err != nil {
+			return &diffResult{err: err}
+		}
+		if job.kind == stashJob {
+			res.changes, res.err = d.inv.stashChanges(job.ctx, c)
+		} else {
+			res.changes, res.err = d.inv.CommitChanges(c)
+		}
+		if res.err != nil {
+			return res
+		}
+		if err := job.ctx.Err(); err != nil {
+			return &diffResult{err: err}
+		}
+		res.lines, res.err = d.patch(job.ctx, res.changes)
+		return res
+	}
+
+	base, err := d.branch(job.base, job.baseHash)
+	if err != nil {
+		return &diffResult{err: err}
+	}
+	br, err := d.branch(job.br, job.brHash)
+	if err != nil {
+		return &diffResult{err: err}
+	}
+	if job.kind == logJob {
+		res.commits, res.err = d.inv.commits(job.ctx, br, base)
+		return res
+	}
+	if res.changes, res.label, res.err = d.inv.changes(job.ctx, base, br, job.threeDot); res.err != nil {
+		return res
+	}
+	if err := job.ctx.Err(); err != nil {
+		return &diffResult{err: err}
+	}
+	if job.kind == patchJob {
+		res.lines, res.err = d.patch(job.ctx, res.changes)
+	}
+	return res
+}
+
+// branch reads a branch tip from the differ repository.
+func (d *differ) branch(name string, h plumbing.Hash) (*Branch, error) {
+	c, err := d.inv.repo.CommitObject(h)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	return &Branch{Name: name, Commit: c, Tree: tree}, nil
+}
+
+// patch renders the changes file by file, to stop early if cancelled.
+func (d *differ) patch(ctx context.Context, changes object.Changes) ([]patchLine, error) {
+	var lines []patchLine
+	for _, c := range changes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		more, err := patchLines(object.Changes{c})
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, more...)
+	}
+	return lines, nil
+}
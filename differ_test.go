@@ -0,0 +1,128 @@
+package gitbr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	git "gopkg.in/src-d/go-git.v4"
+)
+
+func TestDiffer(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n"))
+	r.checkout("feature")
+	r.commit("feature", "feature.txt", "feature\n")
+	r.checkout("master")
+	brs := r.branches()
+
+	repo, err := git.PlainOpen(r.path)
+	assert.NoError(err)
+	d := newDiffer(NewInventory(repo))
+	d.delay = 10 * time.Millisecond
+
+	results := make(chan jobKind, 2)
+	job := func(kind jobKind) diffJob {
+		return diffJob{
+			kind:     kind,
+			base:     "master",
+			baseHash: brs["master"].Commit.Hash,
+			br:       "feature",
+			brHash:   brs["feature"].Commit.Hash,
+			threeDot: true,
+		}
+	}
+
+	// the patch is cancelled by the log before being computed
+	patch := job(patchJob)
+	patch.done = func(*diffResult) { results <- patchJob }
+	d.submit(patch)
+	var res *diffResult
+	log := job(logJob)
+	log.done = func(r *diffResult) {
+		res = r
+		results <- logJob
+	}
+	d.submit(log)
+
+	assert.Equal(logJob, <-results)
+	assert.NoError(res.err)
+	if assert.Len(res.commits, 1) {
+		assert.Equal("feature", subject(res.commits[0].Message))
+	}
+	select {
+	case kind := <-results:
+		t.Errorf("cancelled job %d reported back", kind)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	patch.done = func(r *diffResult) {
+		res = r
+		results <- patchJob
+	}
+	d.submit(patch)
+	assert.Equal(patchJob, <-results)
+	assert.NoError(res.err)
+	assert.Len(res.changes, 1)
+	if assert.NotEmpty(res.lines) {
+		assert.Equal("added feature.txt", res.lines[0].text)
+	}
+}
+
+// cancelAfter is a context cancelled after its error has been checked n
+// times, to cancel a job in the middle of a walk.
+type cancelAfter struct {
+	context.Context
+	n int
+}
+
+func (c *cancelAfter) Err() error {
+	if c.n == 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestDifferCancelledWalk(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n"))
+	r.checkout("feature")
+	for _, name := range []string{"a", "b", "c", "d"} {
+		r.commit(name, name+".txt", name+"\n")
+	}
+	r.checkout("master")
+	r.commit("master", "master.txt", "master\n")
+	brs := r.branches()
+
+	inv := NewInventory(r.repo)
+	d := &differ{inv: inv}
+	job := diffJob{
+		kind:     patchJob,
+		base:     "master",
+		baseHash: brs["master"].Commit.Hash,
+		br:       "feature",
+		brHash:   brs["feature"].Commit.Hash,
+		threeDot: true,
+		ctx:      &cancelAfter{Context: context.Background(), n: 2},
+	}
+	res := d.run(&job)
+	assert.Equal(context.Canceled, res.err)
+	assert.Nil(res.changes)
+	// the walk was given up before reaching the merge base
+	_, ok := inv.walks.get(brs["feature"].Commit.Hash, brs["master"].Commit.Hash)
+	assert.False(ok)
+
+	job.kind = logJob
+	job.ctx = &cancelAfter{Context: context.Background(), n: 3}
+	res = d.run(&job)
+	assert.Equal(context.Canceled, res.err)
+	assert.Nil(res.commits)
+}
@@ -0,0 +1,269 @@
+package gitbr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+
+	"gopkg.in/src-d/go-billy.v2"
+	"gopkg.in/src-d/go-billy.v2/osfs"
+)
+
+// location tells where the pieces of a repository are on disk.
+type location struct {
+	// gitDir is the directory holding HEAD and the index.
+	gitDir string
+	// commonDir is the directory holding the refs, the objects and the
+	// config. It is gitDir but for linked worktrees, made by git worktree
+	// add, which share the ones of the main worktree.
+	commonDir string
+	// workTree is the root of the checked out files, empty for bare repos.
+	workTree string
+}
+
+func (l *location) String() string {
+	if l.workTree == "" {
+		return l.gitDir + " (bare)"
+	}
+	return l.workTree
+}
+
+// discover finds the repository path belongs to, the way git does: it uses
+// GIT_DIR and GIT_WORK_TREE when set and otherwise walks up from path until
+// it finds a .git directory, a .git file pointing to one, like submodules
+// and linked worktrees have, or a bare repository.
+func discover(path string) (*location, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return nil, err
+	}
+
+	var loc *location
+	if gitDir := os.Getenv("GIT_DIR"); gitDir != "" {
+		loc, err = fromEnv(abs, gitDir, os.Getenv("GIT_WORK_TREE"))
+	} else {
+		loc, err = walkUp(abs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if loc.commonDir, err = commonDir(loc.gitDir); err != nil {
+		return nil, err
+	}
+	return loc, nil
+}
+
+func walkUp(abs string) (*location, error) {
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		dotGit := filepath.Join(dir, ".git")
+		fi, err := os.Stat(dotGit)
+		switch {
+		case err == nil && fi.IsDir():
+			return &location{gitDir: dotGit, workTree: dir}, nil
+		case err == nil:
+			gitDir, err := readGitFile(dotGit)
+			if err != nil {
+				return nil, err
+			}
+			return &location{gitDir: gitDir, workTree: dir}, nil
+		case !os.IsNotExist(err):
+			return nil, err
+		case isGitDir(dir) && filepath.Base(dir) == ".git":
+			// inside the .git directory itself
+			return &location{gitDir: dir, workTree: filepath.Dir(dir)}, nil
+		case isGitDir(dir):
+			return &location{gitDir: dir}, nil
+		}
+
+		if filepath.Dir(dir) == dir {
+			return nil, git.ErrRepositoryNotExists
+		}
+	}
+}
+
+func fromEnv(cwd, gitDir, workTree string) (*location, error) {
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(cwd, gitDir)
+	}
+	if !isGitDir(gitDir) {
+		return nil, fmt.Errorf("GIT_DIR %s is not a git repository", gitDir)
+	}
+	switch {
+	case workTree == "":
+		// like git, without GIT_WORK_TREE the current directory is the top
+		workTree = cwd
+	case !filepath.IsAbs(workTree):
+		workTree = filepath.Join(cwd, workTree)
+	}
+	return &location{gitDir: gitDir, workTree: workTree}, nil
+}
+
+// readGitFile returns the directory a "gitdir: <path>" .git file points to.
+func readGitFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	line := strings.TrimSpace(string(content))
+	const prefix = "gitdir: "
+	if !strings.HasPrefix(line, prefix) {
+		return "", fmt.Errorf("%s has no %s prefix", path, prefix)
+	}
+	gitDir := strings.TrimPrefix(line, prefix)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	return filepath.Clean(gitDir), nil
+}
+
+// commonDir returns the directory the commondir file of gitDir points to,
+// gitDir itself if there is none.
+func commonDir(gitDir string) (string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir"))
+	if os.IsNotExist(err) {
+		return gitDir, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	dir := strings.TrimSpace(string(content))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(gitDir, dir)
+	}
+	return filepath.Clean(dir), nil
+}
+
+// isGitDir reports whether dir looks like a git directory. The one of a
+// linked worktree has its objects and refs in its common directory.
+func isGitDir(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil {
+		return false
+	}
+	common, err := commonDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, name := range []string{"objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(common, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// open opens the repository found at the location.
+func (l *location) open() (*git.Repository, error) {
+	var fs billy.Filesystem = osfs.New(l.gitDir)
+	if l.commonDir != "" && l.commonDir != l.gitDir {
+		fs = &linkedFS{own: fs, common: osfs.New(l.commonDir)}
+	}
+	s, err := filesystem.NewStorage(fs)
+	if err != nil {
+		return nil, err
+	}
+	if l.workTree == "" {
+		return git.Open(s, nil)
+	}
+	return git.Open(s, osfs.New(l.workTree))
+}
+
+// linkedFS is the git directory of a linked worktree: the files shared by
+// every worktree, listed by isCommonPath, are in the common directory and
+// the others, like HEAD, the index and logs/HEAD, in its own one.
+type linkedFS struct {
+	own, common billy.Filesystem
+}
+
+// isCommonPath reports whether a path of the git directory is shared by the
+// worktrees, following the list of git.
+func isCommonPath(path string) bool {
+	path = filepath.ToSlash(filepath.Clean(path))
+	switch path {
+	case "logs/HEAD", "info/sparse-checkout":
+		return false
+	case "config", "packed-refs", "shallow", "gc.pid":
+		return true
+	}
+	for _, own := range []string{"refs/bisect", "refs/worktree", "refs/rewritten"} {
+		if path == own || strings.HasPrefix(path, own+"/") {
+			return false
+		}
+	}
+	switch strings.SplitN(path, "/", 2)[0] {
+	case "branches", "common", "hooks", "info", "logs", "lost-found", "objects", "refs", "remotes",
+		"rr-cache", "svn", "worktrees", "gitbr":
+		return true
+	}
+	return false
+}
+
+func (fs *linkedFS) route(path string) billy.Filesystem {
+	if isCommonPath(path) {
+		return fs.common
+	}
+	return fs.own
+}
+
+func (fs *linkedFS) Create(filename string) (billy.File, error) {
+	return fs.route(filename).Create(filename)
+}
+
+func (fs *linkedFS) Open(filename string) (billy.File, error) {
+	return fs.route(filename).Open(filename)
+}
+
+func (fs *linkedFS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	return fs.route(filename).OpenFile(filename, flag, perm)
+}
+
+func (fs *linkedFS) Stat(filename string) (billy.FileInfo, error) {
+	return fs.route(filename).Stat(filename)
+}
+
+func (fs *linkedFS) ReadDir(path string) ([]billy.FileInfo, error) {
+	return fs.route(path).ReadDir(path)
+}
+
+// TempFile creates the temporary files at the top, only used to replace
+// packed-refs, in the common directory.
+func (fs *linkedFS) TempFile(dir, prefix string) (billy.File, error) {
+	if dir == "" {
+		return fs.common.TempFile(dir, prefix)
+	}
+	return fs.route(dir).TempFile(dir, prefix)
+}
+
+// Rename moves a file within the directory of its destination, where the
+// temporary files it is used with are created.
+func (fs *linkedFS) Rename(from, to string) error {
+	return fs.route(to).Rename(from, to)
+}
+
+func (fs *linkedFS) Remove(filename string) error {
+	return fs.route(filename).Remove(filename)
+}
+
+func (fs *linkedFS) MkdirAll(filename string, perm os.FileMode) error {
+	return fs.route(filename).MkdirAll(filename, perm)
+}
+
+func (fs *linkedFS) Join(elem ...string) string {
+	return fs.common.Join(elem...)
+}
+
+func (fs *linkedFS) Dir(path string) billy.Filesystem {
+	return fs.route(path).Dir(path)
+}
+
+// Base returns the common directory, where git-br keeps its own files.
+func (fs *linkedFS) Base() string {
+	return fs.common.Base()
+}
@@ -0,0 +1,114 @@
+package gitbr
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscoverFromSubdirectory(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.commit("initial", "a/b/c.txt", "c\n")
+	loc, err := discover(filepath.Join(r.path, "a", "b"))
+	assert.NoError(err)
+	assert.Equal(r.path, loc.workTree)
+	assert.Equal(filepath.Join(r.path, ".git"), loc.gitDir)
+
+	repo, err := loc.open()
+	assert.NoError(err)
+	_, err = repo.Head()
+	assert.NoError(err)
+}
+
+func TestDiscoverGitFile(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.commit("initial", "README", "hello\n")
+	sub := filepath.Join(r.path, "sub")
+	assert.NoError(os.MkdirAll(sub, 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(sub, ".git"), []byte("gitdir: ../.git\n"), 0644))
+
+	loc, err := discover(sub)
+	assert.NoError(err)
+	assert.Equal(sub, loc.workTree)
+	assert.Equal(filepath.Join(r.path, ".git"), loc.gitDir)
+}
+
+func TestDiscoverLinkedWorktree(t *testing.T) {
+	assert := assert.New(t)
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git binary")
+	}
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n"))
+	linked := filepath.Join(r.path, "linked")
+	_, err := runGit(r.path, "worktree", "add", linked, "feature")
+	assert.NoError(err)
+
+	loc, err := discover(linked)
+	assert.NoError(err)
+	assert.Equal(linked, loc.workTree)
+	assert.Equal(filepath.Join(r.path, ".git", "worktrees", "linked"), loc.gitDir)
+	assert.Equal(filepath.Join(r.path, ".git"), loc.commonDir)
+
+	repo, err := loc.open()
+	if !assert.NoError(err) {
+		return
+	}
+	head, err := repo.Head()
+	assert.NoError(err)
+	assert.Equal("refs/heads/feature", head.Name().String())
+	brs, err := extract(repo)
+	assert.NoError(err)
+	assert.Len(brs, 2)
+	assert.True(brs["feature"].Head)
+	assert.False(brs["master"].Head)
+
+	// branches created from the linked worktree are seen from the main one
+	_, err = createBranch(repo, "other", brs["feature"], false)
+	assert.NoError(err)
+	assert.Contains(r.branches(), "other")
+	assert.True(isCommonPath("refs/heads/other"))
+	assert.False(isCommonPath("HEAD"))
+	assert.False(isCommonPath("logs/HEAD"))
+	assert.True(isCommonPath("logs/refs/stash"))
+}
+
+func TestDiscoverGitDirEnv(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.commit("initial", "README", "hello\n")
+	other, err := ioutil.TempDir("", "git-br")
+	assert.NoError(err)
+	defer os.RemoveAll(other)
+
+	t.Setenv("GIT_DIR", filepath.Join(r.path, ".git"))
+	t.Setenv("GIT_WORK_TREE", r.path)
+
+	loc, err := discover(other)
+	assert.NoError(err)
+	assert.Equal(r.path, loc.workTree)
+	assert.Equal(filepath.Join(r.path, ".git"), loc.gitDir)
+}
+
+func TestDiscoverNotARepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "git-br")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = discover(dir)
+	assert.Error(t, err)
+}
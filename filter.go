@@ -0,0 +1,89 @@
+package gitbr
+
+import (
+	"strings"
+	"unicode"
+)
+
+// fuzzyMatch reports whether the runes of pattern appear in s in the same
+// order, ignoring case, and returns the indexes of the runes of s matched.
+func fuzzyMatch(pattern, s string) ([]int, bool) {
+	want := []rune(pattern)
+	if len(want) == 0 {
+		return nil, true
+	}
+	var matched []int
+	for i, r := range []rune(s) {
+		if unicode.ToLower(r) == unicode.ToLower(want[len(matched)]) {
+			matched = append(matched, i)
+			if len(matched) == len(want) {
+				return matched, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// branchMatch tells where a filter pattern matched a branch: the rune indexes
+// of the name or of the author, both empty if it was the commit subject.
+type branchMatch struct {
+	name   []int
+	author []int
+}
+
+// matchBranch fuzzy matches pattern against the name of br, the author and
+// the subject of its last commit, in that order.
+func matchBranch(pattern string, br *Branch) (branchMatch, bool) {
+	if m, ok := fuzzyMatch(pattern, br.Name); ok {
+		return branchMatch{name: m}, true
+	}
+	if m, ok := fuzzyMatch(pattern, br.Author.Name); ok {
+		return branchMatch{author: m}, true
+	}
+	_, ok := fuzzyMatch(pattern, subject(br.Commit.Message))
+	return branchMatch{}, ok
+}
+
+// subject returns the first line of a commit message.
+func subject(msg string) string {
+	return strings.SplitN(strings.TrimSpace(msg), "\n", 2)[0]
+}
+
+// highlights maps a match to the runes of row, the list line of br, that
+// should be highlighted. Runes cut off from the row by truncation are left
+// out.
+func highlights(row string, br *Branch, m branchMatch, s *settings) map[int]bool {
+	hl := make(map[int]bool)
+	if len(m.name) > 0 {
+		// the name is the last column
+		start := len([]rune(row)) - len([]rune(br.shortName(s.nameWidth)))
+		for _, i := range m.name {
+			if visible(br.Name, br.shortName(s.nameWidth), i) {
+				hl[start+i] = true
+			}
+		}
+	}
+	if len(m.author) > 0 {
+		// the author is the second column, after the date
+		idx := strings.Index(row, "  "+br.shortAuthor(s.authorWidth))
+		if idx < 0 {
+			return hl
+		}
+		start := len([]rune(row[:idx+2]))
+		for _, i := range m.author {
+			if visible(br.Author.Name, br.shortAuthor(s.authorWidth), i) {
+				hl[start+i] = true
+			}
+		}
+	}
+	return hl
+}
+
+// visible reports whether the rune i of a column is shown in its shortened
+// form, that is, it is not cut off nor replaced by the trailing cutTail.
+func visible(col, short string, i int) bool {
+	if col == short {
+		return i < len([]rune(col))
+	}
+	return i < len([]rune(short))-len([]rune(cutTail))
+}
@@ -0,0 +1,82 @@
+package gitbr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	m, ok := fuzzyMatch("fxbg", "feature/fix-bug")
+	assert.True(ok)
+	assert.Equal([]int{0, 10, 12, 14}, m)
+
+	m, ok = fuzzyMatch("FIX", "feature/fix-bug")
+	assert.True(ok)
+	assert.Equal([]int{0, 9, 10}, m)
+
+	_, ok = fuzzyMatch("gb", "feature/fix-bug")
+	assert.False(ok)
+
+	_, ok = fuzzyMatch("", "anything")
+	assert.True(ok)
+}
+
+func TestMatchBranch(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature/login", r.commit("add the oauth flow\n\nmore details", "README", "hello\n"))
+	br := r.branches()["feature/login"]
+
+	m, ok := matchBranch("login", br)
+	assert.True(ok)
+	assert.Equal([]int{8, 9, 10, 11, 12}, m.name)
+
+	m, ok = matchBranch("gph", br)
+	assert.True(ok)
+	assert.Empty(m.name)
+	assert.Equal([]int{0, 2, 3}, m.author)
+
+	m, ok = matchBranch("oauth", br)
+	assert.True(ok)
+	assert.Equal(branchMatch{}, m)
+
+	_, ok = matchBranch("details", br)
+	assert.False(ok)
+
+	rows := listItems([]*Branch{br}, defaultSettings())
+	hl := highlights(rows[0], br, branchMatch{name: []int{0}}, defaultSettings())
+	assert.Len(hl, 1)
+	for i := range hl {
+		assert.Equal('f', []rune(rows[0])[i])
+	}
+	hl = highlights(rows[0], br, branchMatch{author: []int{0}}, defaultSettings())
+	assert.Len(hl, 1)
+	for i := range hl {
+		assert.Equal('g', []rune(rows[0])[i])
+	}
+}
+
+func TestHighlightsCutAuthor(t *testing.T) {
+	assert := assert.New(t)
+
+	br := &Branch{Name: "feature", Author: object.Signature{Name: "Åsa Östlund-Ärlebäck"}}
+	s := defaultSettings()
+	s.authorWidth = 13
+	m, ok := matchBranch("ölä", br)
+	assert.True(ok)
+
+	rows := listItems([]*Branch{br}, s)
+	assert.Contains(rows[0], "Åsa Östlund-…")
+	hl := highlights(rows[0], br, m, s)
+	// the Ä of Ärlebäck is cut off
+	assert.Len(hl, 2)
+	for i := range hl {
+		assert.Contains("Öl", string([]rune(rows[0])[i]))
+	}
+}
@@ -0,0 +1,22 @@
+package gitbr
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// runGit runs a git command in dir for the operations go-git does not
+// implement, like stashes, returning its trimmed standard output.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -5,12 +5,11 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/marcusolsson/tui-go"
 	"github.com/prometheus/log"
-	"github.com/ryanuber/columnize"
 	git "gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
 )
 
@@ -19,69 +18,102 @@ type UIRunner interface {
 	Run() error
 }
 
-// Open returns an UIRunner from a git repository filesystem path.
-func Open(path string) (UIRunner, error) {
-	repo, err := git.PlainOpen(path)
+// Option configures Open and List.
+type Option func(*options)
+
+type options struct {
+	base     string
+	format   string
+	template string
+}
+
+// WithBase sets the branch the others are compared against. By default it is
+// taken from the gitbr.base setting or the remote HEAD, falling back to the
+// usual main branch names.
+func WithBase(name string) Option {
+	return func(o *options) { o.base = name }
+}
+
+// Open returns an UIRunner from a git repository filesystem path. The path
+// can be anywhere inside the repository worktree.
+func Open(path string, opts ...Option) (UIRunner, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	loc, err := discover(path)
 	if err != nil {
 		return nil, err
 	}
-
-	brs, err := extract(repo)
+	repo, err := loc.open()
 	if err != nil {
 		return nil, err
 	}
 
-	return newTuiUI(repo, brs), nil
-}
+	s, err := loadSettings(repo)
+	if err != nil {
+		return nil, err
+	}
+	inv := NewInventory(repo)
+	brs, err := inv.Branches()
+	if err != nil {
+		return nil, err
+	}
 
-type branch struct {
-	Name   string
-	Author object.Signature
-	Branch plumbing.ReferenceName
-	Tree   *object.Tree
-}
+	if _, ok := brs[o.base]; o.base != "" && !ok {
+		return nil, fmt.Errorf("base branch %s not found", o.base)
+	}
 
-func (b branch) String() string {
-	author := b.Author.Name
-	if len(author) > 16 {
-		author = author[0:15] + "..."
+	// unlike the flag, a configured base that does not exist falls back to
+	// the defaults
+	name := o.base
+	if name == "" {
+		name = s.base
 	}
-	name := b.Name
-	if len(name) > 32 {
-		name = name[0:31] + "..."
+	// the differ reads the diffs from its own repository in the background
+	diffRepo, err := loc.open()
+	if err != nil {
+		return nil, err
 	}
-	return fmt.Sprintf("%s|%s|o %s", b.Author.When.String()[2:19], author, name)
+	return newTuiUI(inv, loc, brs, inv.Base(brs, name), s, newDiffer(inv.fork(diffRepo))), nil
 }
 
-type branches map[string]*branch
-
-func (brs branches) sort() []*branch {
-	var list []*branch
-	for _, br := range brs {
-		list = append(list, br)
+func extract(repo *git.Repository) (Branches, error) {
+	refs, err := repo.Branches()
+	if err != nil {
+		return nil, err
 	}
-	// sort by date desc
-	sort.Slice(list, func(i, j int) bool { return list[i].Author.When.Unix() > list[j].Author.When.Unix() })
-	return list
-}
 
-func (brs branches) displayData() []string {
-	var data []string
-	for _, br := range brs.sort() {
-		data = append(data, br.String())
+	brs, err := extractRefs(repo, refs)
+	if err != nil {
+		return nil, err
 	}
-	data = strings.Split(columnize.SimpleFormat(data), "\n")
-	return data
+	if head, err := repo.Head(); err == nil && head.IsBranch() {
+		if br, ok := brs[head.Name().Short()]; ok {
+			br.Head = true
+		}
+	}
+	return brs, nil
 }
 
-func extract(repo *git.Repository) (branches, error) {
-	brs, err := repo.Branches()
+// extractRemotes returns the remote-tracking branches, refs/remotes/*.
+func extractRemotes(repo *git.Repository) (Branches, error) {
+	refs, err := repo.References()
 	if err != nil {
 		return nil, err
 	}
 
-	brsByName := make(branches)
-	err = brs.ForEach(func(br *plumbing.Reference) error {
+	remotes := storer.NewReferenceFilteredIter(func(ref *plumbing.Reference) bool {
+		// skip the symbolic ones, like refs/remotes/origin/HEAD
+		return ref.IsRemote() && ref.Type() == plumbing.HashReference
+	}, refs)
+	return extractRefs(repo, remotes)
+}
+
+func extractRefs(repo *git.Repository, refs storer.ReferenceIter) (Branches, error) {
+	brsByName := make(Branches)
+	err := refs.ForEach(func(br *plumbing.Reference) error {
 		commit, err := repo.CommitObject(br.Hash())
 		if err != nil {
 			log.Error(err.Error())
@@ -93,8 +125,17 @@ func extract(repo *git.Repository) (branches, error) {
 			return nil
 		}
 		name := br.Name()
-		branch := &branch{name.Short(), commit.Author, name, tree}
-		brsByName[name.Short()] = branch
+		b := &Branch{
+			Name:   name.Short(),
+			Author: commit.Author,
+			Ref:    name,
+			Commit: commit,
+			Tree:   tree,
+		}
+		if br.IsRemote() {
+			b.Remote = strings.SplitN(name.Short(), "/", 2)[0]
+		}
+		brsByName[name.Short()] = b
 		return nil
 	})
 
@@ -105,108 +146,18 @@ func extract(repo *git.Repository) (branches, error) {
 	return brsByName, nil
 }
 
-func newTuiUI(repo *git.Repository, brs branches) tui.UI {
-	t := tui.NewTable(0, 0)
-
-	sortedBrs := brs.sort()
-	var brStr []string
-	for _, br := range sortedBrs {
-		t.AppendRow(
-			tui.NewLabel(br.Author.When.String()[2:19]),
-			tui.NewLabel(br.Author.Name),
-			tui.NewLabel(br.Name),
-		)
-		brStr = append(brStr, br.String())
-
-	}
-	brStr = strings.Split(columnize.SimpleFormat(brStr), "\n")
-	l := tui.NewList()
-	l.AddItems(brStr...)
-	l.SetFocused(true)
-
-	diffView := tui.NewLabel("")
-
-	status := tui.NewStatusBar("")
-	status.SetText("[press enter to switch to selected branch]")
-	status.SetPermanentText("[press esc or q to quit]")
-	diffBox := tui.NewVBox(diffView, tui.NewSpacer())
-	diffBox.SetBorder(true)
-	tableBox := tui.NewVBox(l, tui.NewSpacer())
-	tableBox.SetBorder(true)
-	top := tui.NewHBox(tableBox, diffBox)
-	root := tui.NewVBox(
-		top,
-		status,
-	)
-
-	th := tui.NewTheme()
-	th.SetStyle("table.cell.selected", tui.Style{Bg: tui.ColorGreen, Fg: tui.ColorWhite})
-	th.SetStyle("list.item", tui.Style{Bg: tui.ColorBlack, Fg: tui.ColorWhite})
-	th.SetStyle("list.item.selected", tui.Style{Bg: tui.ColorGreen, Fg: tui.ColorWhite})
-
-	ui := tui.New(root)
-	ui.SetTheme(th)
-	ui.SetKeybinding("Esc", func() { ui.Quit() })
-	ui.SetKeybinding("q", func() { ui.Quit() })
-	l.OnItemActivated(func(l *tui.List) {
-		w, err := repo.Worktree()
-		if err != nil {
-			status.SetText(err.Error())
-			return
-		}
-		br := sortedBrs[l.Selected()]
-		err = w.Checkout(&git.CheckoutOptions{
-			Branch: br.Branch,
-			Force:  true,
-		})
-
-		if err != nil {
-			status.SetText(err.Error())
-			return
-		}
-		status.SetText("switched to " + br.Name)
-	})
-	l.OnSelectionChanged(func(l *tui.List) {
-		br := sortedBrs[l.Selected()]
-		fromBrName := "master"
-		if br.Name == fromBrName {
-			diffView.SetText("")
-			return
-		}
-		fromBr, ok := brs[fromBrName]
-		if !ok {
-			status.SetText(fmt.Sprintf("no origin %s branch", fromBrName))
-			return
-		}
-		changes, err := object.DiffTree(fromBr.Tree, br.Tree)
-		if err != nil {
-			status.SetText(err.Error())
-			return
-		}
-		var changesMsg string
-		if len(changes) == 0 {
-			changesMsg = fmt.Sprintf("no changes between %s and %s", fromBrName, br.Name)
-		} else {
-			changesMsg = changesToString(fromBrName, changes)
-		}
-		diffView.SetText(changesMsg)
-	})
-	l.Select(0)
-
-	return ui
-}
-
-func changesToString(fromBrName string, changes object.Changes) string {
-	changesMsg := fmt.Sprintf("changes against %s:\n\n", fromBrName)
-	if len(changes) > 30 {
-		changesMsg += "\ttoo many changes to display :-("
-		return changesMsg
+// changedFiles returns the changes sorted by action and file name, along
+// with the line listing each of them, like "M: main.go".
+func changedFiles(changes object.Changes) ([]*object.Change, []string) {
+	type file struct {
+		change *object.Change
+		line   string
 	}
-	var changesList []string
+	var files []file
 	for _, c := range changes {
 		action, err := c.Action()
 		if err != nil {
-			// @TODO log
+			log.Error(err.Error())
 			continue
 		}
 		var actionStr, fileName string
@@ -221,20 +172,14 @@ func changesToString(fromBrName string, changes object.Changes) string {
 			actionStr = "M"
 			fileName = c.From.Name
 		}
-		changesList = append(changesList, fmt.Sprintf("    %s: %s", actionStr, fileName))
+		files = append(files, file{c, fmt.Sprintf("%s: %s", actionStr, fileName)})
 	}
+	sort.Slice(files, func(i, j int) bool { return files[i].line < files[j].line })
 
-	sort.Strings(changesList)
-	var newList []string
-	var lastChar string
-	for i, c := range changesList {
-		firstChar := strings.TrimLeft(c, " ")[0:1]
-		if firstChar != lastChar && i > 0 {
-			newList = append(newList, "")
-		}
-		newList = append(newList, c)
-		lastChar = firstChar
+	sorted := make([]*object.Change, len(files))
+	lines := make([]string, len(files))
+	for i, f := range files {
+		sorted[i], lines[i] = f.change, f.line
 	}
-
-	return changesMsg + strings.Join(newList, "\n")
+	return sorted, lines
 }
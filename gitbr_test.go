@@ -17,8 +17,18 @@ func TestOpenInvalidPathErrors(t *testing.T) {
 
 func TestOpenCurrentRepository(t *testing.T) {
 	assert := assert.New(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
 
 	ui, err := gitbr.Open("")
 	assert.NoError(err)
 	assert.NotNil(ui)
 }
+
+func TestOpenFromSubdirectory(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	ui, err := gitbr.Open("cmd/git-br")
+	assert.NoError(err)
+	assert.NotNil(ui)
+}
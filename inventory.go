@@ -0,0 +1,359 @@
+package gitbr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	runewidth "github.com/mattn/go-runewidth"
+	"github.com/prometheus/log"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// Branch is a local or remote-tracking branch and the tip commit it points to.
+type Branch struct {
+	Name   string
+	Author object.Signature
+	Ref    plumbing.ReferenceName
+	Commit *object.Commit
+	Tree   *object.Tree
+	// Remote is the name of the remote of a remote-tracking branch, empty for
+	// local branches.
+	Remote string
+	// Head is set for the checked out branch.
+	Head bool
+	// Annotation is the tagger and message of an annotated tag, nil for
+	// branches and lightweight tags.
+	Annotation *Annotation
+	// Base and Upstream count the commits ahead and behind the base branch
+	// and the upstream, nil if there is none. They are filled by
+	// Inventory.Compare.
+	Base     *Divergence
+	Upstream *Divergence
+}
+
+func (b Branch) String() string {
+	return b.row(defaultAuthorWidth, defaultNameWidth)
+}
+
+// row returns the columns of the branch list, separated by |, with the
+// author and the name cut to the given widths.
+func (b Branch) row(authorWidth, nameWidth int) string {
+	marker := "o"
+	switch {
+	case b.Head:
+		marker = "*"
+	case b.Remote != "":
+		marker = "r"
+	case b.isTag():
+		marker = "t"
+	}
+	var base, up string
+	if b.Base != nil {
+		base = b.Base.String()
+	}
+	if b.Upstream != nil {
+		up = "[" + b.Upstream.String() + "]"
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%s %s", b.Author.When.String()[2:19], b.shortAuthor(authorWidth), base, up, marker, b.shortName(nameWidth))
+}
+
+// shortAuthor and shortName are the author and the name as shown in the
+// branch list, cut to fit in width.
+func (b Branch) shortAuthor(width int) string {
+	return cut(b.Author.Name, width)
+}
+
+func (b Branch) shortName(width int) string {
+	return cut(b.Name, width)
+}
+
+// cutTail ends the values cut by cut.
+const cutTail = "…"
+
+// cut shortens s to width columns, ending it with cutTail if it is longer.
+func cut(s string, width int) string {
+	return runewidth.Truncate(s, width, cutTail)
+}
+
+// Branches are branches by name.
+type Branches map[string]*Branch
+
+// Sorted returns the branches by author date, newest first, and by name when
+// the dates are the same.
+func (brs Branches) Sorted() []*Branch {
+	var list []*Branch
+	for _, br := range brs {
+		list = append(list, br)
+	}
+	// sort by date desc
+	sort.Slice(list, func(i, j int) bool {
+		if ti, tj := list[i].Author.When.Unix(), list[j].Author.When.Unix(); ti != tj {
+			return ti > tj
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Inventory reads the branches of a repository and what git-br shows about
+// them, independently of any UI.
+type Inventory struct {
+	repo *git.Repository
+	// walks caches the ancestry of pairs of commits, diffs the changes
+	// between pairs of trees.
+	walks *walkCache
+	diffs *lru
+}
+
+// NewInventory returns an Inventory of the branches of repo. The ancestry of
+// the branches is cached in the gitbr directory of the git one.
+func NewInventory(repo *git.Repository) *Inventory {
+	return &Inventory{repo: repo, walks: newWalkCache(repo), diffs: newLRU(diffCacheSize)}
+}
+
+// fork returns an Inventory of another handle of the same repository that
+// shares the ancestry cache, for another goroutine to use.
+func (inv *Inventory) fork(repo *git.Repository) *Inventory {
+	return &Inventory{repo: repo, walks: inv.walks, diffs: newLRU(diffCacheSize)}
+}
+
+// Repository returns the repository the branches are read from.
+func (inv *Inventory) Repository() *git.Repository {
+	return inv.repo
+}
+
+// Branches returns the local branches, refs/heads/*.
+func (inv *Inventory) Branches() (Branches, error) {
+	return extract(inv.repo)
+}
+
+// Remotes returns the remote-tracking branches, refs/remotes/*.
+func (inv *Inventory) Remotes() (Branches, error) {
+	return extractRemotes(inv.repo)
+}
+
+// Tags returns the tags of commits, refs/tags/*, annotated tags at the
+// commit they point to.
+func (inv *Inventory) Tags() (Branches, error) {
+	return extractTags(inv.repo)
+}
+
+// Base returns the name of the branch of brs to compare the others against.
+// It is name if it exists, otherwise the branch pointed by
+// refs/remotes/origin/HEAD or the first of main, master, develop and trunk
+// that exists. It returns an empty string if none does.
+func (inv *Inventory) Base(brs Branches, name string) string {
+	return resolveBase(inv.repo, brs, name)
+}
+
+// Compare fills the Base and Upstream divergences of brs against base and
+// their upstreams. A nil base leaves Base empty.
+func (inv *Inventory) Compare(brs Branches, base *Branch) {
+	defer inv.walks.flush()
+	// the upstreams of all the branches come from a single read of the config
+	cfg, err := inv.repo.Config()
+	if err != nil {
+		log.Error(err.Error())
+	}
+	for _, br := range brs {
+		br.Base, br.Upstream = nil, nil
+		if base != nil && br != base {
+			w, err := inv.walk(context.Background(), br.Commit.Hash, base.Commit.Hash)
+			if err != nil {
+				log.Error(err.Error())
+			} else {
+				br.Base = &w.Divergence
+			}
+		}
+		if br.Remote != "" || br.isTag() || cfg == nil {
+			continue
+		}
+		ref, ok := configUpstream(cfg, br.Name)
+		if !ok {
+			continue
+		}
+		if h, ok := refHash(inv.repo, ref); ok {
+			w, err := inv.walk(context.Background(), br.Commit.Hash, h)
+			if err != nil {
+				log.Error(err.Error())
+			} else {
+				br.Upstream = &w.Divergence
+			}
+		}
+	}
+}
+
+// Upstream returns the ref configured as upstream of br, if any.
+func (inv *Inventory) Upstream(br *Branch) (plumbing.ReferenceName, bool) {
+	return upstream(inv.repo, br.Name)
+}
+
+// Divergence counts the commits br is ahead and behind other.
+func (inv *Inventory) Divergence(br, other *Branch) (Divergence, error) {
+	defer inv.walks.flush()
+	w, err := inv.walk(context.Background(), br.Commit.Hash, other.Commit.Hash)
+	return w.Divergence, err
+}
+
+// Merged reports whether the tip of br is reachable from into.
+func (inv *Inventory) Merged(br, into *Branch) (bool, error) {
+	defer inv.walks.flush()
+	w, err := inv.walk(context.Background(), br.Commit.Hash, into.Commit.Hash)
+	return w.Ahead == 0, err
+}
+
+// MergeBase returns the best common ancestor of a and b, nil if their
+// histories are unrelated.
+func (inv *Inventory) MergeBase(a, b *Branch) (*object.Commit, error) {
+	defer inv.walks.flush()
+	return inv.mergeBase(context.Background(), a, b)
+}
+
+func (inv *Inventory) mergeBase(ctx context.Context, a, b *Branch) (*object.Commit, error) {
+	w, err := inv.walk(ctx, a.Commit.Hash, b.Commit.Hash)
+	if err != nil || w.base == plumbing.ZeroHash {
+		return nil, err
+	}
+	return inv.repo.CommitObject(w.base)
+}
+
+// walk returns the divergence and merge base of two commits, from the cache
+// if they were walked before. A walk given up because ctx is done is not
+// cached.
+func (inv *Inventory) walk(ctx context.Context, a, b plumbing.Hash) (walk, error) {
+	if w, ok := inv.walks.get(a, b); ok {
+		return w, nil
+	}
+	pw, err := paint(ctx, inv.repo, a, b)
+	if err != nil {
+		return walk{}, err
+	}
+	w := walk{Divergence: pw.divergence()}
+	if pw.base != nil {
+		w.base = pw.base.Hash
+	}
+	inv.walks.add(a, b, w)
+	return w, nil
+}
+
+// diffTree returns the changes between two trees, from the cache if they were
+// diffed before. A nil from is the empty tree.
+func (inv *Inventory) diffTree(from, to *object.Tree) (object.Changes, error) {
+	var key hashPair
+	if from != nil {
+		key.a = from.Hash
+	}
+	key.b = to.Hash
+	if changes, ok := inv.diffs.get(key); ok {
+		return changes.(object.Changes), nil
+	}
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, err
+	}
+	inv.diffs.add(key, changes)
+	return changes, nil
+}
+
+// Changes returns the files changed in br against base and a label telling
+// what they were computed from. With threeDot the changes are taken from the
+// merge base, like git diff base...br, otherwise from the tip of base.
+func (inv *Inventory) Changes(base, br *Branch, threeDot bool) (object.Changes, string, error) {
+	defer inv.walks.flush()
+	return inv.changes(context.Background(), base, br, threeDot)
+}
+
+// changes is Changes giving up with the error of ctx once it is done.
+func (inv *Inventory) changes(ctx context.Context, base, br *Branch, threeDot bool) (object.Changes, string, error) {
+	from, label, err := diffFrom(ctx, inv, base, br, threeDot)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	changes, err := inv.diffTree(from, br.Tree)
+	if err != nil {
+		return nil, "", err
+	}
+	return changes, label, nil
+}
+
+// Commits returns the commits of br that are not in base, the base..br range
+// in git terms, newest first.
+func (inv *Inventory) Commits(br, base *Branch) ([]*object.Commit, error) {
+	return inv.commits(context.Background(), br, base)
+}
+
+// commits is Commits giving up with the error of ctx once it is done.
+func (inv *Inventory) commits(ctx context.Context, br, base *Branch) ([]*object.Commit, error) {
+	commits, err := commitsNotIn(ctx, inv.repo, br.Commit.Hash, base.Commit.Hash)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commits[i].Committer.When.After(commits[j].Committer.When)
+	})
+	return commits, nil
+}
+
+// CommitChanges returns the files changed by c against its first parent, all
+// of its files for a root commit.
+func (inv *Inventory) CommitChanges(c *object.Commit) (object.Changes, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var parentTree *object.Tree
+	if len(c.ParentHashes) > 0 {
+		parent, err := inv.repo.CommitObject(c.ParentHashes[0])
+		if err != nil {
+			return nil, err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	return inv.diffTree(parentTree, tree)
+}
+
+// Create creates a branch at the tip of from. With track, from becomes its
+// upstream.
+func (inv *Inventory) Create(name string, from *Branch, track bool) (*Branch, error) {
+	return createBranch(inv.repo, name, from, track)
+}
+
+// Track creates a local branch tracking the remote-tracking branch remote.
+func (inv *Inventory) Track(remote *Branch) (*Branch, error) {
+	return createTracking(inv.repo, remote)
+}
+
+// Rename moves br to a new name, together with its upstream and the rest of
+// its branch.<name> config, and returns the renamed branch.
+func (inv *Inventory) Rename(br *Branch, name string) (*Branch, error) {
+	return renameBranch(inv.repo, br, name)
+}
+
+// Delete removes br and its branch.<name> config, merged or not.
+func (inv *Inventory) Delete(br *Branch) error {
+	return deleteBranch(inv.repo, br)
+}
+
+// MergedInto returns the branches of brs merged into the base one, sorted by
+// name, leaving out the checked out branch and the protected ones.
+func (inv *Inventory) MergedInto(brs Branches, base string, protected []string) ([]*Branch, error) {
+	return mergedBranches(inv.repo, brs, base, protected)
+}
+
+// DeleteAll removes all the branches or, if any of them cannot be, none.
+func (inv *Inventory) DeleteAll(brs []*Branch) error {
+	return deleteBranches(inv.repo, brs)
+}
+
+// planDelete tells how many commits deleting br would lose.
+func (inv *Inventory) planDelete(brs Branches, br *Branch, base string) (*deletePlan, error) {
+	return planDelete(inv.repo, brs, br, base)
+}
@@ -0,0 +1,115 @@
+package gitbr
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	runewidth "github.com/mattn/go-runewidth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInventory(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("done", r.commit("initial", "README", "hello\n"))
+	r.branch("feature", r.commit("second", "README", "hello world\n"))
+	r.checkout("feature")
+	r.commit("feature", "feature.txt", "feature\n")
+	r.checkout("master")
+	assert.NoError(setUpstream(r.repo, "feature", ".", "refs/heads/master"))
+
+	inv := NewInventory(r.repo)
+	brs, err := inv.Branches()
+	assert.NoError(err)
+	assert.Len(brs, 3)
+	assert.Equal([]string{"feature", "master", "done"}, names(brs.Sorted()))
+	assert.True(brs["master"].Head)
+	assert.False(brs["feature"].Head)
+	assert.Contains(brs["master"].String(), "* master")
+
+	assert.Equal("master", inv.Base(brs, ""))
+	base := brs["master"]
+
+	inv.Compare(brs, base)
+	assert.Nil(base.Base)
+	assert.Equal(&Divergence{Ahead: 1}, brs["feature"].Base)
+	assert.Equal(&Divergence{Behind: 1}, brs["done"].Base)
+	assert.Equal(&Divergence{Ahead: 1}, brs["feature"].Upstream)
+
+	merged, err := inv.Merged(brs["done"], base)
+	assert.NoError(err)
+	assert.True(merged)
+	merged, err = inv.Merged(brs["feature"], base)
+	assert.NoError(err)
+	assert.False(merged)
+
+	up, ok := inv.Upstream(brs["feature"])
+	assert.True(ok)
+	assert.Equal("master", up.Short())
+
+	changes, label, err := inv.Changes(base, brs["feature"], true)
+	assert.NoError(err)
+	assert.Contains(label, "merge base")
+	if assert.Len(changes, 1) {
+		assert.Equal("feature.txt", changes[0].To.Name)
+	}
+}
+
+func names(brs []*Branch) []string {
+	var list []string
+	for _, br := range brs {
+		list = append(list, br.Name)
+	}
+	return list
+}
+
+func TestInventoryCommits(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	root := r.commit("initial", "README", "hello\n")
+	r.branch("feature", root)
+	r.checkout("feature")
+	one := r.commit("one", "a.txt", "a\n")
+	two := r.commit("two", "README", "hello world\n")
+	r.checkout("master")
+	r.commit("master moves on", "master.txt", "master\n")
+
+	inv := NewInventory(r.repo)
+	brs, err := inv.Branches()
+	assert.NoError(err)
+
+	commits, err := inv.Commits(brs["feature"], brs["master"])
+	assert.NoError(err)
+	if assert.Len(commits, 2) {
+		assert.Equal(two, commits[0].Hash)
+		assert.Equal(one, commits[1].Hash)
+	}
+
+	changes, err := inv.CommitChanges(commits[0])
+	assert.NoError(err)
+	if assert.Len(changes, 1) {
+		assert.Equal("README", changes[0].To.Name)
+	}
+
+	rootCommit, err := r.repo.CommitObject(root)
+	assert.NoError(err)
+	changes, err = inv.CommitChanges(rootCommit)
+	assert.NoError(err)
+	assert.Len(changes, 1)
+}
+
+func TestCut(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("master", cut("master", 10))
+	assert.Equal("feature/l…", cut("feature/login", 10))
+
+	short := cut("Åsa Östlund-Ärlebäck", 13)
+	assert.Equal("Åsa Östlund-…", short)
+	assert.True(utf8.ValidString(short))
+	assert.Equal(13, runewidth.StringWidth(short))
+}
@@ -0,0 +1,259 @@
+package gitbr
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/marcusolsson/tui-go"
+	"github.com/ryanuber/columnize"
+)
+
+// action is something the keys of the branch browser can be bound to in the
+// [gitbr "keys"] config section.
+type action struct {
+	name string
+	key  string
+	help string
+	// short is how the status bar names the action, if it lists it.
+	short string
+}
+
+// actions are the bindable actions with their default keys, in the order the
+// help lists them. Their handlers are in tuiUI.handlers.
+var actions = []action{
+	{"switch", "Enter", "switch to the selected branch", ""},
+	{"focus", "Tab", "move between the branches and the changed files, commits or stashes", "files/log"},
+	{"down", "j", "move down, or scroll the opened diff down", ""},
+	{"up", "k", "move up, or scroll the opened diff up", ""},
+	{"top", "g", "go to the first line, or to line N with a count", ""},
+	{"bottom", "G", "go to the last line, or to line N with a count", ""},
+	{"filter", "/", "filter the branches while typing", "filter"},
+	{"sort", "s", "cycle the order of the branches", "sort"},
+	{"base", "b", "change the base branch", "base"},
+	{"diffmode", "t", "diff against the merge base or the tip of the base branch", "diff mode"},
+	{"diff", "v", "show the full diff instead of the changed files", "diff view"},
+	{"log", "l", "show the commits not in the base branch", "log"},
+	{"stashes", "S", "show the stashes, enter on one to view, apply, pop or drop it", "stashes"},
+	{"stashfilter", "f", "show only the stashes made on the selected branch, or all of them", ""},
+	{"pagedown", "PgDn", "scroll the diff a page down", ""},
+	{"pageup", "PgUp", "scroll the diff a page up", ""},
+	{"nextfile", "]", "jump to the next file of the diff", ""},
+	{"prevfile", "[", "jump to the previous file of the diff", ""},
+	{"remotes", "r", "show or hide the remote-tracking branches", "remotes"},
+	{"tags", "T", "show or hide the tags", "tags"},
+	{"refresh", "R", "read the branches again", ""},
+	{"new", "n", "create a branch at the selected one", "new"},
+	{"tag", "a", "create an annotated tag at the selected one", ""},
+	{"rename", "m", "rename the selected branch", "rename"},
+	{"delete", "d", "delete the selected branch", "delete"},
+	{"prune", "p", "delete the branches merged into the base branch", "prune merged"},
+	{"help", "?", "show this help", "help"},
+	{"quit", "q", "quit", ""},
+}
+
+func findAction(name string) (action, bool) {
+	for _, a := range actions {
+		if a.name == name {
+			return a, true
+		}
+	}
+	return action{}, false
+}
+
+// keyName normalizes a key for lookups: single characters are case
+// sensitive, so g and G are different keys, but names like PgDn are not.
+// Control keys are named both Ctrl-R and Ctrl+R by tui-go.
+func keyName(key string) string {
+	if utf8.RuneCountInString(key) == 1 {
+		return key
+	}
+	return strings.Replace(strings.ToLower(key), "ctrl-", "ctrl+", 1)
+}
+
+// handlers returns the function run by every action.
+func (u *tuiUI) handlers() map[string]func() {
+	return map[string]func(){
+		"switch": func() {
+			if u.sideFocused {
+				u.activateSide()
+				return
+			}
+			if br := u.selected(); br != nil {
+				u.switchBranch(br)
+			}
+		},
+		"focus": func() {
+			u.closePatch()
+			u.focusSide(!u.sideFocused)
+		},
+		"down": func() { u.move(u.repeat()) },
+		"up":   func() { u.move(-u.repeat()) },
+		"top":  func() { u.moveTo(u.repeat() - 1) },
+		"bottom": func() {
+			if u.count == 0 {
+				u.moveTo(math.MaxInt32)
+				return
+			}
+			u.moveTo(u.count - 1)
+		},
+		"filter":      u.startFilter,
+		"sort":        u.cycleSort,
+		"base":        u.changeBase,
+		"diffmode":    u.toggleDiffMode,
+		"diff":        func() { u.setPane(patchPane) },
+		"log":         func() { u.setPane(logPane) },
+		"stashes":     func() { u.setPane(stashPane) },
+		"stashfilter": u.toggleStashFilter,
+		"pagedown":    func() { u.pagePatch(u.repeat()) },
+		"pageup":      func() { u.pagePatch(-u.repeat()) },
+		"nextfile":    func() { u.jumpFiles(false) },
+		"prevfile":    func() { u.jumpFiles(true) },
+		"remotes":     u.toggleRemotes,
+		"tags":        u.toggleTags,
+		"refresh":     u.reload,
+		"new":         func() { u.newBranch(u.selected()) },
+		"tag":         func() { u.newTag(u.selected()) },
+		"rename":      func() { u.renameBranch(u.selected()) },
+		"delete":      func() { u.deleteBranch(u.selected()) },
+		"prune":       u.pruneMerged,
+		"help":        u.showHelp,
+		"quit":        func() { u.Quit() },
+	}
+}
+
+// bindActions binds the keys of the settings to the actions.
+func (u *tuiUI) bindActions() {
+	handlers := u.handlers()
+	for _, a := range actions {
+		u.bind(u.settings.keys[a.name], handlers[a.name])
+	}
+}
+
+// keyRouter hands every key to the tuiUI before the widgets get it, as
+// tui-go keybindings cannot tell g from G. The widgets only get the keys
+// dispatch did not use, so the key opening an entry is not typed in it and
+// the lists do not move on j and k by themselves.
+type keyRouter struct {
+	tui.Widget
+	u *tuiUI
+}
+
+func (r *keyRouter) OnKeyEvent(ev tui.KeyEvent) {
+	if !r.u.dispatch(ev.Name()) {
+		r.Widget.OnKeyEvent(ev)
+	}
+}
+
+// setWidget shows w with the keys routed through dispatch.
+func (u *tuiUI) setWidget(w tui.Widget) {
+	u.SetWidget(&keyRouter{Widget: w, u: u})
+}
+
+// countKey adds a digit typed before an action to the count it is repeated,
+// reporting whether key was one.
+func (u *tuiUI) countKey(key string) bool {
+	if len(key) != 1 || key[0] < '0' || key[0] > '9' || (key == "0" && u.count == 0) {
+		return false
+	}
+	u.count = u.count*10 + int(key[0]-'0')
+	u.status.SetText(fmt.Sprintf("%d", u.count))
+	return true
+}
+
+// repeat returns the count typed before the action, 1 if none.
+func (u *tuiUI) repeat() int {
+	if u.count > 0 {
+		return u.count
+	}
+	return 1
+}
+
+// move moves the cursor of the focused list, or scrolls the opened diff, by
+// delta lines.
+func (u *tuiUI) move(delta int) {
+	if u.opened {
+		u.scrollPatch(u.patchTop + delta)
+		return
+	}
+	l, _ := u.cursor()
+	u.moveTo(l.Selected() + delta)
+}
+
+// moveTo moves the cursor of the focused list, or scrolls the opened diff, to
+// line i, kept within the lines.
+func (u *tuiUI) moveTo(i int) {
+	if u.opened {
+		u.scrollPatch(i)
+		return
+	}
+	l, n := u.cursor()
+	if n == 0 {
+		return
+	}
+	if i >= n {
+		i = n - 1
+	}
+	if i < 0 {
+		i = 0
+	}
+	l.Select(i)
+}
+
+// jumpFiles jumps as many files as the count typed before.
+func (u *tuiUI) jumpFiles(back bool) {
+	for i := 0; i < u.repeat(); i++ {
+		u.jumpFile(back)
+	}
+}
+
+// cursor returns the focused list and its length.
+func (u *tuiUI) cursor() (*tui.List, int) {
+	if u.sideFocused {
+		return u.side()
+	}
+	return u.list.List, len(u.shown)
+}
+
+// reload reads the branches again, for the changes made outside git-br.
+func (u *tuiUI) reload() {
+	if err := u.refresh(); err != nil {
+		u.status.SetText(err.Error())
+		return
+	}
+	u.status.SetText(fmt.Sprintf("read %d branches", len(u.brs)))
+}
+
+// showHelp lists every action with the key bound to it, until esc, the help
+// or the quit key.
+func (u *tuiUI) showHelp() {
+	lines := []string{"key|action|"}
+	for _, a := range actions {
+		lines = append(lines, fmt.Sprintf("%s|%s|%s", u.settings.keys[a.name], a.name, a.help))
+	}
+	lines = append(lines, "Esc||go back: close the diff, leave the right pane, clear the filter or quit")
+	list := tui.NewList()
+	list.AddItems(strings.Split(columnize.SimpleFormat(lines), "\n")...)
+	box := tui.NewVBox(
+		tui.NewLabel("a number before down, up, top, bottom, page down, page up and the file jumps repeats them, "+
+			"rebind the keys in the gitbr \"keys\" config section"),
+		tui.NewLabel(""),
+		list,
+		tui.NewSpacer(),
+	)
+	box.SetBorder(true)
+
+	keys := u.keys
+	u.keys = make(map[string]func())
+	done := func() {
+		u.keys = keys
+		u.setWidget(u.root)
+	}
+	u.bind("Esc", done)
+	u.bind(u.settings.keys["help"], done)
+	u.bind(u.settings.keys["quit"], done)
+
+	u.setWidget(tui.NewVBox(box, u.status))
+	u.status.SetText("esc to go back")
+}
@@ -0,0 +1,95 @@
+package gitbr
+
+import (
+	"testing"
+
+	"github.com/marcusolsson/tui-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActions(t *testing.T) {
+	assert := assert.New(t)
+
+	handlers := (&tuiUI{}).handlers()
+	assert.Len(handlers, len(actions))
+	for _, a := range actions {
+		assert.NotNil(handlers[a.name], a.name)
+	}
+	assert.NoError(defaultSettings().checkKeys())
+	assert.NotEqual(keyName("g"), keyName("G"))
+	assert.Equal(keyName("PgDn"), keyName("pgdn"))
+	assert.Equal(keyName("Ctrl+R"), keyName("Ctrl-R"))
+}
+
+func TestDispatchCount(t *testing.T) {
+	assert := assert.New(t)
+	u := &tuiUI{status: tui.NewStatusBar(""), keys: make(map[string]func())}
+	var counts []int
+	u.bind("j", func() { counts = append(counts, u.repeat()) })
+	u.bind("G", func() { counts = append(counts, u.count) })
+
+	for _, key := range []string{"j", "1", "2", "j", "j", "0", "G", "3", "Esc", "j", "G"} {
+		u.dispatch(key)
+	}
+	assert.Equal([]int{1, 12, 1, 0, 1, 0}, counts)
+}
+
+func TestRouterMovesOnce(t *testing.T) {
+	assert := assert.New(t)
+	u := &tuiUI{
+		status:   tui.NewStatusBar(""),
+		keys:     make(map[string]func()),
+		list:     &branchList{List: tui.NewList()},
+		shown:    make([]*Branch, 10),
+		settings: defaultSettings(),
+	}
+	u.list.AddItems("0", "1", "2", "3", "4", "5", "6", "7", "8", "9")
+	u.list.SetSelected(0)
+	u.list.SetFocused(true)
+	u.bindActions()
+	router := &keyRouter{Widget: u.list, u: u}
+
+	press := func(r rune) tui.KeyEvent { return tui.KeyEvent{Key: tui.KeyRune, Rune: r} }
+	router.OnKeyEvent(press('j'))
+	assert.Equal(1, u.list.Selected())
+	router.OnKeyEvent(press('5'))
+	router.OnKeyEvent(press('j'))
+	assert.Equal(6, u.list.Selected())
+	router.OnKeyEvent(press('k'))
+	assert.Equal(5, u.list.Selected())
+	// keys not bound to any action still reach the list
+	router.OnKeyEvent(tui.KeyEvent{Key: tui.KeyDown})
+	assert.Equal(6, u.list.Selected())
+}
+
+func TestRouterChainedInputs(t *testing.T) {
+	assert := assert.New(t)
+	u := &tuiUI{
+		status:    tui.NewStatusBar(""),
+		keys:      make(map[string]func()),
+		list:      &branchList{List: tui.NewList()},
+		files:     tui.NewList(),
+		log:       tui.NewList(),
+		stashList: tui.NewList(),
+	}
+	u.bottom = tui.NewVBox(u.status)
+	var got []string
+	// like newTag, the second entry opens when the first one is submitted
+	u.bind("a", func() {
+		u.input("name:", "", func(name string) {
+			u.input("message:", "", func(msg string) { got = []string{name, msg} })
+		})
+	})
+	router := &keyRouter{Widget: u.bottom, u: u}
+	typeText := func(s string) {
+		for _, r := range s {
+			router.OnKeyEvent(tui.KeyEvent{Key: tui.KeyRune, Rune: r})
+		}
+		router.OnKeyEvent(tui.KeyEvent{Key: tui.KeyEnter})
+	}
+
+	router.OnKeyEvent(tui.KeyEvent{Key: tui.KeyRune, Rune: 'a'})
+	typeText("v1")
+	typeText("release")
+	assert.Equal([]string{"v1", "release"}, got)
+}
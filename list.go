@@ -0,0 +1,157 @@
+package gitbr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ryanuber/columnize"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// listEntry is what List prints for every branch. Its fields are the ones
+// available to the templates.
+type listEntry struct {
+	Name     string    `json:"name"`
+	Ref      string    `json:"ref"`
+	SHA      string    `json:"sha"`
+	Author   string    `json:"author"`
+	Email    string    `json:"email"`
+	Date     time.Time `json:"date"`
+	Upstream string    `json:"upstream,omitempty"`
+	Merged   bool      `json:"merged"`
+}
+
+// WithFormat sets the output format of List: table, the default, tsv or json.
+func WithFormat(format string) Option {
+	return func(o *options) { o.format = format }
+}
+
+// WithTemplate makes List print every branch with a text/template, like
+// '{{.Name}} {{.SHA}}'. It takes precedence over the format.
+func WithTemplate(tmpl string) Option {
+	return func(o *options) { o.template = tmpl }
+}
+
+// List writes the branches of the repository at path to w, newest first,
+// without running the UI. Merged tells whether the branch tip is reachable
+// from the base branch.
+func List(w io.Writer, path string, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	loc, err := discover(path)
+	if err != nil {
+		return err
+	}
+	repo, err := loc.open()
+	if err != nil {
+		return err
+	}
+	s, err := loadSettings(repo)
+	if err != nil {
+		return err
+	}
+	inv := NewInventory(repo)
+	brs, err := inv.Branches()
+	if err != nil {
+		return err
+	}
+	if _, ok := brs[o.base]; o.base != "" && !ok {
+		return fmt.Errorf("base branch %s not found", o.base)
+	}
+
+	name := o.base
+	if name == "" {
+		name = s.base
+	}
+	entries, err := listEntries(inv, brs, inv.Base(brs, name))
+	if err != nil {
+		return err
+	}
+
+	if o.template != "" {
+		return writeTemplate(w, o.template, entries)
+	}
+	switch o.format {
+	case "", "table":
+		return writeTable(w, entries)
+	case "tsv":
+		return writeTSV(w, entries)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	return fmt.Errorf("unknown format %s, use table, tsv or json", o.format)
+}
+
+func listEntries(inv *Inventory, brs Branches, baseName string) ([]listEntry, error) {
+	// one walk of the base branch tells which branches are merged into it
+	var inBase map[plumbing.Hash]bool
+	if base, ok := brs[baseName]; ok {
+		var err error
+		if inBase, err = reachable(context.Background(), inv.repo, base.Commit.Hash); err != nil {
+			return nil, err
+		}
+	}
+	entries := []listEntry{}
+	for _, br := range brs.Sorted() {
+		e := listEntry{
+			Name:   br.Name,
+			Ref:    br.Ref.String(),
+			SHA:    br.Commit.Hash.String(),
+			Author: br.Author.Name,
+			Email:  br.Author.Email,
+			Date:   br.Author.When,
+		}
+		e.Merged = inBase[br.Commit.Hash]
+		if ref, ok := inv.Upstream(br); ok {
+			e.Upstream = ref.Short()
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func writeTemplate(w io.Writer, text string, entries []listEntry) error {
+	tmpl, err := template.New("list").Parse(text)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := tmpl.Execute(w, e); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTable(w io.Writer, entries []listEntry) error {
+	lines := []string{"NAME|SHA|DATE|AUTHOR|UPSTREAM|MERGED"}
+	for _, e := range entries {
+		lines = append(lines, fmt.Sprintf("%s|%s|%s|%s|%s|%t",
+			e.Name, e.SHA[0:7], e.Date.Format("2006-01-02 15:04"), e.Author, e.Upstream, e.Merged))
+	}
+	_, err := fmt.Fprintln(w, columnize.SimpleFormat(lines))
+	return err
+}
+
+func writeTSV(w io.Writer, entries []listEntry) error {
+	for _, e := range entries {
+		fields := []string{e.Name, e.Ref, e.SHA, e.Author, e.Email, e.Date.Format(time.RFC3339), e.Upstream, fmt.Sprint(e.Merged)}
+		if _, err := fmt.Fprintln(w, strings.Join(fields, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
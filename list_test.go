@@ -0,0 +1,47 @@
+package gitbr
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList(t *testing.T) {
+	assert := assert.New(t)
+	noUserConfig(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("merged", r.commit("initial", "README", "hello\n"))
+	r.commit("second", "README", "hello world\n")
+	r.branch("feature", r.commit("third", "feature.txt", "feature\n"))
+	r.checkout("feature")
+	feature := r.commit("fourth", "feature.txt", "more\n")
+	r.checkout("master")
+	assert.NoError(setUpstream(r.repo, "feature", "origin", "refs/heads/feature"))
+
+	var out bytes.Buffer
+	assert.NoError(List(&out, r.path, WithTemplate("{{.Name}} {{.Merged}} {{.Upstream}}")))
+	assert.Equal("feature false origin/feature\nmaster true \nmerged true \n", out.String())
+
+	out.Reset()
+	assert.NoError(List(&out, r.path, WithFormat("json")))
+	var entries []listEntry
+	assert.NoError(json.Unmarshal(out.Bytes(), &entries))
+	if assert.Len(entries, 3) {
+		assert.Equal("feature", entries[0].Name)
+		assert.Equal("refs/heads/feature", entries[0].Ref)
+		assert.Equal(feature.String(), entries[0].SHA)
+		assert.Equal("gopher", entries[0].Author)
+	}
+
+	out.Reset()
+	assert.NoError(List(&out, r.path, WithFormat("tsv"), WithBase("feature")))
+	assert.Contains(out.String(), "merged\trefs/heads/merged\t")
+	assert.Contains(out.String(), "\ttrue\n")
+
+	assert.Error(List(&out, r.path, WithFormat("xml")))
+	assert.Error(List(&out, r.path, WithBase("nope")))
+}
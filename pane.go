@@ -0,0 +1,234 @@
+package gitbr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/marcusolsson/tui-go"
+	"github.com/ryanuber/columnize"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// paneMode is what the right pane shows about the selected branch.
+type paneMode int
+
+const (
+	// filesPane lists the files changed against the base branch.
+	filesPane paneMode = iota
+	// patchPane shows the full diff against the base branch.
+	patchPane
+	// logPane lists the commits not in the base branch.
+	logPane
+	// stashPane lists the stashes, all of them or the ones made on the
+	// selected branch.
+	stashPane
+)
+
+// setPane switches the right pane to mode, or back to the changed files if
+// it already shows it.
+func (u *tuiUI) setPane(mode paneMode) {
+	if u.pane == mode {
+		mode = filesPane
+	}
+	u.focusSide(false)
+	u.pane, u.opened = mode, false
+	u.layoutDiff()
+	switch mode {
+	case patchPane:
+		u.status.SetText("showing the diff, pgup/pgdn to scroll, [ and ] to jump between files")
+	case logPane:
+		u.status.SetText("showing the commits not in " + u.base + ", tab to pick one")
+	case stashPane:
+		u.status.SetText(fmt.Sprintf("showing the stashes, tab to pick one, %s for the ones of the selected branch only", u.settings.keys["stashfilter"]))
+	default:
+		u.status.SetText("showing the changed files")
+	}
+	if br := u.selected(); br != nil {
+		u.showChanges(br)
+	}
+}
+
+// patchShown reports whether the right pane shows a diff rather than a list.
+func (u *tuiUI) patchShown() bool {
+	return u.pane == patchPane || u.opened
+}
+
+func (u *tuiUI) layoutDiff() {
+	u.diffBox.Remove(1)
+	switch {
+	case u.patchShown():
+		u.diffBox.Insert(1, u.patchArea)
+	case u.pane == logPane:
+		u.diffBox.Insert(1, u.logBox)
+	case u.pane == stashPane:
+		u.diffBox.Insert(1, u.stashBox)
+	default:
+		u.diffBox.Insert(1, u.filesBox)
+	}
+}
+
+// side returns the list shown in the right pane and its length, nil if it
+// shows the full diff.
+func (u *tuiUI) side() (*tui.List, int) {
+	switch u.pane {
+	case filesPane:
+		return u.files, len(u.changes)
+	case logPane:
+		return u.log, len(u.commits)
+	case stashPane:
+		return u.stashList, len(u.stashes)
+	}
+	return nil, 0
+}
+
+// focusSide moves the keyboard focus to the list in the right pane, if it
+// has any items, or back to the branches.
+func (u *tuiUI) focusSide(on bool) {
+	l, n := u.side()
+	if on && n == 0 {
+		return
+	}
+	u.sideFocused = on
+	if on && l.Selected() < 0 {
+		l.Select(0)
+	}
+	u.refocus()
+}
+
+// refocus gives the keyboard focus back to the list that had it.
+func (u *tuiUI) refocus() {
+	u.list.SetFocused(!u.sideFocused)
+	u.files.SetFocused(u.sideFocused && u.pane == filesPane)
+	u.log.SetFocused(u.sideFocused && u.pane == logPane)
+	u.stashList.SetFocused(u.sideFocused && u.pane == stashPane)
+}
+
+// blur takes the keyboard focus from every list, for an entry or a dialog to
+// get it.
+func (u *tuiUI) blur() {
+	u.list.SetFocused(false)
+	u.files.SetFocused(false)
+	u.log.SetFocused(false)
+	u.stashList.SetFocused(false)
+}
+
+// setChanges lists the changed files, up to the maxChanges setting and then
+// a line telling how many more there are, moving the focus back to the
+// branches if there are none.
+func (u *tuiUI) setChanges(changes object.Changes) {
+	var items []string
+	u.changes, items = changedFiles(changes)
+	if max := u.settings.maxChanges; max > 0 && len(items) > max {
+		more := fmt.Sprintf("too many changes, %d more files not listed, %s for the full diff", len(items)-max, u.settings.keys["diff"])
+		u.changes, items = u.changes[:max], append(items[:max], more)
+	}
+	u.files.RemoveItems()
+	u.files.AddItems(items...)
+	u.resetSide(filesPane, len(u.changes))
+}
+
+// setCommits lists the commits of the log pane.
+func (u *tuiUI) setCommits(commits []*object.Commit) {
+	u.commits = commits
+	u.log.RemoveItems()
+	if len(commits) > 0 {
+		var lines []string
+		for _, c := range commits {
+			lines = append(lines, fmt.Sprintf("%s|%s|%s|%s",
+				c.Hash.String()[0:7], c.Committer.When.Format("2006-01-02 15:04"), c.Author.Name, subject(c.Message)))
+		}
+		u.log.AddItems(strings.Split(columnize.SimpleFormat(lines), "\n")...)
+	}
+	u.resetSide(logPane, len(commits))
+}
+
+// setStashes lists the stashes of the stash pane.
+func (u *tuiUI) setStashes(stashes []stash) {
+	u.stashes = stashes
+	u.stashList.RemoveItems()
+	if len(stashes) > 0 {
+		var lines []string
+		for _, s := range stashes {
+			lines = append(lines, fmt.Sprintf("%s|%s|%s|%s", s.ref(), s.When.Format("2006-01-02 15:04"), s.Branch, s.Message))
+		}
+		u.stashList.AddItems(strings.Split(columnize.SimpleFormat(lines), "\n")...)
+	}
+	u.resetSide(stashPane, len(stashes))
+}
+
+func (u *tuiUI) resetSide(mode paneMode, n int) {
+	if !u.sideFocused || u.pane != mode {
+		return
+	}
+	if n == 0 {
+		u.focusSide(false)
+		return
+	}
+	l, _ := u.side()
+	l.Select(0)
+}
+
+// activateSide opens the item under the cursor of the right pane list: the
+// diff of a file or a commit, or the actions of a stash.
+func (u *tuiUI) activateSide() {
+	l, n := u.side()
+	if u.opened || l == nil {
+		return
+	}
+	i := l.Selected()
+	if i < 0 || i >= n {
+		return
+	}
+	switch u.pane {
+	case filesPane:
+		u.openFile(u.changes[i])
+	case logPane:
+		u.openCommit(u.commits[i])
+	case stashPane:
+		u.stashActions(u.stashes[i])
+	}
+}
+
+// openFile shows the diff of one of the changed files in place of the list.
+func (u *tuiUI) openFile(c *object.Change) {
+	u.openPatch(diffJob{kind: fileJob, change: c}, "pgup/pgdn to scroll, esc to go back to the changed files")
+}
+
+// openCommit shows the diff of a commit against its first parent in place of
+// the log.
+func (u *tuiUI) openCommit(c *object.Commit) {
+	u.openPatch(diffJob{kind: commitJob, commit: c.Hash}, fmt.Sprintf("%s %s, esc to go back to the log", c.Hash.String()[0:7], subject(c.Message)))
+}
+
+// openStash shows the diff of a stash against the commit it was made on, and
+// its untracked files, in place of the stashes.
+func (u *tuiUI) openStash(s stash) {
+	u.openPatch(diffJob{kind: stashJob, commit: s.Hash}, fmt.Sprintf("%s %s, esc to go back to the stashes", s.ref(), s.Message))
+}
+
+// openPatch shows the diff computed by job, empty until it is done.
+func (u *tuiUI) openPatch(job diffJob, status string) {
+	u.opened = true
+	u.layoutDiff()
+	u.setPatch(nil)
+	u.status.SetText("computing…")
+	u.computeDiff(job, func(res *diffResult) {
+		if res.err != nil {
+			u.status.SetText(res.err.Error())
+			return
+		}
+		u.setPatch(res.lines)
+		u.status.SetText(status)
+	})
+}
+
+// closePatch goes back from an opened diff to the list it was opened from.
+func (u *tuiUI) closePatch() {
+	if !u.opened {
+		return
+	}
+	u.stopDiff()
+	u.opened = false
+	u.layoutDiff()
+	u.setPatch(nil)
+}
@@ -0,0 +1,42 @@
+package gitbr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marcusolsson/tui-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShowDiffCutFiles(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n"))
+	r.checkout("feature")
+	r.commit("feature", "a.txt", "a\n", "b.txt", "b\n", "c.txt", "c\n")
+	brs := r.branches()
+	changes, label, err := NewInventory(r.repo).Changes(brs["master"], brs["feature"], false)
+	assert.NoError(err)
+
+	s := defaultSettings()
+	s.maxChanges = 2
+	s.keys["diff"] = "D"
+	u := &tuiUI{settings: s, diffView: tui.NewLabel(""), files: tui.NewList(), pane: filesPane}
+	u.showDiff(brs["feature"], &diffResult{changes: changes, label: label})
+
+	assert.Len(u.changes, 2)
+	var items []string
+	for i := 0; i < u.files.Length(); i++ {
+		u.files.Select(i)
+		items = append(items, u.files.SelectedItem())
+	}
+	if assert.Len(items, 3) {
+		assert.Equal("too many changes, 1 more files not listed, D for the full diff", items[2])
+	}
+	// the header does not tell it again
+	text := u.diffView.Text() + strings.Join(items, "\n")
+	assert.Equal(1, strings.Count(text, "not listed"))
+	assert.NotContains(text, "of 3 files")
+}
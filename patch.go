@@ -0,0 +1,171 @@
+package gitbr
+
+import (
+	"fmt"
+	"strings"
+
+	fdiff "gopkg.in/src-d/go-git.v4/plumbing/format/diff"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// contextLines is the number of unchanged lines shown around the changes,
+// like git diff does by default.
+const contextLines = 3
+
+type lineKind int
+
+const (
+	fileLine lineKind = iota
+	hunkLine
+	contextLine
+	addedLine
+	deletedLine
+	binaryLine
+)
+
+// patchLine is a line of a unified diff. Old and New are its numbers in each
+// side, 0 if it is not there.
+type patchLine struct {
+	kind lineKind
+	old  int
+	new  int
+	text string
+}
+
+// String returns the line as shown by the patch view, with the line numbers
+// of the changed and unchanged lines before it.
+func (l patchLine) String() string {
+	num := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return fmt.Sprint(n)
+	}
+	text := strings.Replace(l.text, "\t", "    ", -1)
+	switch l.kind {
+	case contextLine:
+		return fmt.Sprintf("%4s %4s  %s", num(l.old), num(l.new), text)
+	case addedLine:
+		return fmt.Sprintf("%4s %4s +%s", "", num(l.new), text)
+	case deletedLine:
+		return fmt.Sprintf("%4s %4s -%s", num(l.old), "", text)
+	}
+	return text
+}
+
+// patchLines renders the changes as a unified diff: a header per file
+// followed by its hunks, or a placeholder for binary files.
+func patchLines(changes object.Changes) ([]patchLine, error) {
+	patch, err := changes.Patch()
+	if err != nil {
+		return nil, err
+	}
+	var lines []patchLine
+	for _, fp := range patch.FilePatches() {
+		lines = append(lines, patchLine{kind: fileLine, text: fileHeader(fp)})
+		if fp.IsBinary() {
+			lines = append(lines, patchLine{kind: binaryLine, text: "binary file, not shown"})
+			continue
+		}
+		lines = append(lines, hunks(fp.Chunks())...)
+	}
+	return lines, nil
+}
+
+func fileHeader(fp fdiff.FilePatch) string {
+	from, to := fp.Files()
+	switch {
+	case from == nil:
+		return "added " + to.Path()
+	case to == nil:
+		return "deleted " + from.Path()
+	case from.Path() != to.Path():
+		return "renamed " + from.Path() + " -> " + to.Path()
+	}
+	return "modified " + to.Path()
+}
+
+// hunks splits the chunks of a file in lines and groups the changed ones
+// with their context in hunks.
+func hunks(chunks []fdiff.Chunk) []patchLine {
+	var all []patchLine
+	var old, new int
+	for _, c := range chunks {
+		content := strings.TrimSuffix(c.Content(), "\n")
+		if c.Content() == "" {
+			continue
+		}
+		for _, text := range strings.Split(content, "\n") {
+			switch c.Type() {
+			case fdiff.Equal:
+				old++
+				new++
+				all = append(all, patchLine{kind: contextLine, old: old, new: new, text: text})
+			case fdiff.Add:
+				new++
+				all = append(all, patchLine{kind: addedLine, new: new, text: text})
+			case fdiff.Delete:
+				old++
+				all = append(all, patchLine{kind: deletedLine, old: old, text: text})
+			}
+		}
+	}
+
+	var lines []patchLine
+	for i := 0; i < len(all); {
+		if all[i].kind == contextLine {
+			i++
+			continue
+		}
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+		// extend the hunk while the next change is close enough for their
+		// contexts to touch
+		end, last := i, i
+		for end < len(all) && end-last <= 2*contextLines {
+			if all[end].kind != contextLine {
+				last = end
+			}
+			end++
+		}
+		end = last + contextLines + 1
+		if end > len(all) {
+			end = len(all)
+		}
+		lines = append(lines, hunkHeader(all, start, end))
+		lines = append(lines, all[start:end]...)
+		i = end
+	}
+	return lines
+}
+
+// hunkHeader returns the "@@ -l,s +l,s @@" line of the hunk all[start:end].
+func hunkHeader(all []patchLine, start, end int) patchLine {
+	var oldBefore, newBefore, oldCount, newCount int
+	for _, l := range all[:start] {
+		if l.old != 0 {
+			oldBefore = l.old
+		}
+		if l.new != 0 {
+			newBefore = l.new
+		}
+	}
+	for _, l := range all[start:end] {
+		if l.kind != addedLine {
+			oldCount++
+		}
+		if l.kind != deletedLine {
+			newCount++
+		}
+	}
+	oldStart, newStart := oldBefore, newBefore
+	if oldCount > 0 {
+		oldStart++
+	}
+	if newCount > 0 {
+		newStart++
+	}
+	return patchLine{kind: hunkLine, text: fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldStart, oldCount, newStart, newCount)}
+}
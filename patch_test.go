@@ -0,0 +1,66 @@
+package gitbr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestPatchLines(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	var letters []string
+	for c := 'a'; c <= 't'; c++ {
+		letters = append(letters, string(c))
+	}
+	before := strings.Join(letters, "\n") + "\n"
+	letters[1] = "B"
+	letters[17] = "R"
+	after := strings.Join(letters, "\n") + "\n"
+
+	r.branch("feature", r.commit("initial", "letters.txt", before, "old.txt", "old\n"))
+	r.checkout("feature")
+	if _, err := r.w.Remove("old.txt"); err != nil {
+		t.Fatal(err)
+	}
+	r.commit("change", "letters.txt", after, "image.png", "\x89PNG\x00\x01\x02")
+	r.checkout("master")
+
+	brs := r.branches()
+	changes, err := object.DiffTree(brs["master"].Tree, brs["feature"].Tree)
+	assert.NoError(err)
+	lines, err := patchLines(changes)
+	assert.NoError(err)
+
+	var texts []string
+	for _, l := range lines {
+		texts = append(texts, l.String())
+	}
+	assert.Equal([]string{
+		"added image.png",
+		"binary file, not shown",
+		"modified letters.txt",
+		"@@ -1,5 +1,5 @@",
+		"   1    1  a",
+		"   2      -b",
+		"        2 +B",
+		"   3    3  c",
+		"   4    4  d",
+		"   5    5  e",
+		"@@ -15,6 +15,6 @@",
+		"  15   15  o",
+		"  16   16  p",
+		"  17   17  q",
+		"  18      -r",
+		"       18 +R",
+		"  19   19  s",
+		"  20   20  t",
+		"deleted old.txt",
+		"@@ -1,1 +0,0 @@",
+		"   1      -old",
+	}, texts)
+}
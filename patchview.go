@@ -0,0 +1,99 @@
+package gitbr
+
+import (
+	"image"
+
+	"github.com/marcusolsson/tui-go"
+	runewidth "github.com/mattn/go-runewidth"
+)
+
+// patchView draws a unified diff, each line styled after its kind. It is
+// meant to be wrapped in a tui.ScrollArea.
+type patchView struct {
+	tui.WidgetBase
+	lines []patchLine
+	texts []string
+	width int
+}
+
+func (v *patchView) setLines(lines []patchLine) {
+	v.lines, v.texts, v.width = lines, nil, 0
+	for _, l := range lines {
+		text := l.String()
+		v.texts = append(v.texts, text)
+		if w := runewidth.StringWidth(text); w > v.width {
+			v.width = w
+		}
+	}
+}
+
+// SizeHint returns the size of the whole diff.
+func (v *patchView) SizeHint() image.Point {
+	return image.Pt(v.width, len(v.lines))
+}
+
+var lineStyles = map[lineKind]string{
+	fileLine:    "diff.file",
+	hunkLine:    "diff.hunk",
+	addedLine:   "diff.added",
+	deletedLine: "diff.deleted",
+	binaryLine:  "diff.binary",
+}
+
+func (v *patchView) Draw(p *tui.Painter) {
+	for i, l := range v.lines {
+		text := v.texts[i]
+		p.WithStyle(lineStyles[l.kind], func(p *tui.Painter) {
+			p.DrawText(0, i, text)
+		})
+	}
+}
+
+// setPatch shows lines in the diff, scrolled to the top.
+func (u *tuiUI) setPatch(lines []patchLine) {
+	u.patch.setLines(lines)
+	u.scrollPatch(0)
+}
+
+// scrollPatch scrolls the diff to the line top, kept within the diff.
+func (u *tuiUI) scrollPatch(top int) {
+	if max := len(u.patch.lines) - u.patchArea.Size().Y; top > max {
+		top = max
+	}
+	if top < 0 {
+		top = 0
+	}
+	u.patchArea.Scroll(0, top-u.patchTop)
+	u.patchTop = top
+}
+
+func (u *tuiUI) pagePatch(pages int) {
+	if !u.patchShown() {
+		return
+	}
+	u.scrollPatch(u.patchTop + pages*u.patchArea.Size().Y)
+}
+
+// jumpFile scrolls the diff to the header of the next file, or the previous
+// one when back is set.
+func (u *tuiUI) jumpFile(back bool) {
+	if !u.patchShown() {
+		return
+	}
+	target := -1
+	for i, l := range u.patch.lines {
+		if l.kind != fileLine {
+			continue
+		}
+		if back && i < u.patchTop {
+			target = i
+		}
+		if !back && i > u.patchTop {
+			target = i
+			break
+		}
+	}
+	if target >= 0 {
+		u.scrollPatch(target)
+	}
+}
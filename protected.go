@@ -0,0 +1,17 @@
+package gitbr
+
+import (
+	"path"
+)
+
+const gitbrSection = "gitbr"
+
+// isProtected reports whether the branch name matches any of the patterns.
+func isProtected(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
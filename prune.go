@@ -0,0 +1,84 @@
+package gitbr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// mergedBranches returns the branches whose tip is an ancestor of the base
+// branch, sorted by name. The checked out branch, the base branch itself and
+// the protected ones are never returned.
+func mergedBranches(repo *git.Repository, brs Branches, baseName string, protected []string) ([]*Branch, error) {
+	base, ok := brs[baseName]
+	if !ok {
+		return nil, fmt.Errorf("no base %s branch", baseName)
+	}
+	inBase, err := reachable(context.Background(), repo, base.Commit.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var head plumbing.ReferenceName
+	if ref, err := repo.Head(); err == nil {
+		head = ref.Name()
+	}
+
+	var merged []*Branch
+	for _, br := range brs {
+		if br == base || br.Ref == head || isProtected(br.Name, protected) {
+			continue
+		}
+		if inBase[br.Commit.Hash] {
+			merged = append(merged, br)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+	return merged, nil
+}
+
+// deleteBranches removes all the given branches or none of them: if any ref
+// or the config cannot be updated, the refs already removed are restored.
+func deleteBranches(repo *git.Repository, brs []*Branch) error {
+	for _, br := range brs {
+		ref, err := repo.Storer.Reference(br.Ref)
+		if err != nil {
+			return fmt.Errorf("%s: %s", br.Name, err)
+		}
+		if ref.Hash() != br.Commit.Hash {
+			return fmt.Errorf("%s has moved since it was listed", br.Name)
+		}
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+
+	var deleted []*Branch
+	for _, br := range brs {
+		if err := removeRef(repo, br.Ref); err != nil {
+			return rollback(repo, deleted, fmt.Errorf("%s: %s", br.Name, err))
+		}
+		deleted = append(deleted, br)
+		cfg.Raw.RemoveSubsection(branchSection, br.Name)
+	}
+
+	if err := repo.Storer.SetConfig(cfg); err != nil {
+		return rollback(repo, deleted, err)
+	}
+	return nil
+}
+
+func rollback(repo *git.Repository, deleted []*Branch, cause error) error {
+	for _, br := range deleted {
+		ref := plumbing.NewHashReference(br.Ref, br.Commit.Hash)
+		if err := repo.Storer.SetReference(ref); err != nil {
+			return fmt.Errorf("%s, and %s could not be restored: %s", cause, br.Name, err)
+		}
+	}
+	return cause
+}
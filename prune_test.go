@@ -0,0 +1,47 @@
+package gitbr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergedBranches(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	h := r.commit("initial", "README", "hello\n")
+	r.branch("done", h)
+	r.branch("release/1.0", h)
+	r.branch("wip", h)
+	r.checkout("wip")
+	r.commit("wip", "wip.txt", "wip\n")
+	r.checkout("master")
+	r.commit("second", "README", "hello world\n")
+
+	merged, err := mergedBranches(r.repo, r.branches(), "master", []string{"release/*"})
+	assert.NoError(err)
+	if assert.Len(merged, 1) {
+		assert.Equal("done", merged[0].Name)
+	}
+}
+
+func TestDeleteBranchesIsAllOrNothing(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	h := r.commit("initial", "README", "hello\n")
+	r.branch("one", h)
+	r.branch("two", h)
+	brs := r.branches()
+	r.branch("two", r.commit("second", "README", "hello world\n"))
+
+	assert.Error(deleteBranches(r.repo, []*Branch{brs["one"], brs["two"]}))
+	assert.Len(r.branches(), 3)
+
+	brs = r.branches()
+	assert.NoError(deleteBranches(r.repo, []*Branch{brs["one"], brs["two"]}))
+	assert.Len(r.branches(), 1)
+}
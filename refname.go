@@ -0,0 +1,50 @@
+package gitbr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkRefName validates a branch or tag name against the rules of
+// git check-ref-format --branch.
+func checkRefName(name string) error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("invalid name %q: %s", name, reason)
+	}
+
+	switch {
+	case name == "":
+		return invalid("it is empty")
+	case name == "@":
+		return invalid("it cannot be @")
+	case name == "HEAD":
+		return invalid("it cannot be HEAD")
+	case strings.HasPrefix(name, "-"):
+		return invalid("it cannot start with -")
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return invalid("it cannot start or end with /")
+	case strings.HasSuffix(name, "."):
+		return invalid("it cannot end with .")
+	case strings.Contains(name, ".."):
+		return invalid("it cannot contain ..")
+	case strings.Contains(name, "//"):
+		return invalid("it cannot contain //")
+	case strings.Contains(name, "@{"):
+		return invalid("it cannot contain @{")
+	}
+
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return invalid(fmt.Sprintf("it cannot contain %q", r))
+		}
+	}
+	for _, c := range strings.Split(name, "/") {
+		if strings.HasPrefix(c, ".") {
+			return invalid("no component can start with .")
+		}
+		if strings.HasSuffix(c, ".lock") {
+			return invalid("no component can end with .lock")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package gitbr
+
+import (
+	"fmt"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// renameBranch moves the branch ref to a new name together with its
+// branch.<name> config section, and updates HEAD when the branch is checked
+// out. It returns the renamed branch.
+func renameBranch(repo *git.Repository, br *Branch, name string) (*Branch, error) {
+	if err := checkRefName(name); err != nil {
+		return nil, err
+	}
+	ref := plumbing.ReferenceName("refs/heads/" + name)
+	if _, err := repo.Storer.Reference(ref); err == nil {
+		return nil, fmt.Errorf("branch %s already exists", name)
+	}
+
+	// the old ref goes first so feature can become feature/x and the other
+	// way around, the files backing them would clash otherwise
+	if err := removeRef(repo, br.Ref); err != nil {
+		return nil, err
+	}
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(ref, br.Commit.Hash)); err != nil {
+		return nil, rollback(repo, []*Branch{br}, err)
+	}
+	// undo moves the ref back when the config cannot follow it
+	undo := func(cause error) error {
+		if err := removeRef(repo, ref); err != nil {
+			return fmt.Errorf("%s, and %s could not be restored: %s", cause, br.Name, err)
+		}
+		return rollback(repo, []*Branch{br}, cause)
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, undo(err)
+	}
+	s := cfg.Raw.Section(branchSection)
+	if s.HasSubsection(br.Name) {
+		cfg.Raw.RemoveSubsection(branchSection, name)
+		s.Subsection(br.Name).Name = name
+		if err := repo.Storer.SetConfig(cfg); err != nil {
+			return nil, undo(err)
+		}
+	}
+
+	head, err := repo.Storer.Reference(plumbing.HEAD)
+	if err == nil && head.Type() == plumbing.SymbolicReference && head.Target() == br.Ref {
+		if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, ref)); err != nil {
+			return nil, err
+		}
+	}
+
+	renamed := *br
+	renamed.Name = name
+	renamed.Ref = ref
+	return &renamed, nil
+}
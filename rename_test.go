@@ -0,0 +1,63 @@
+package gitbr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestCheckRefName(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, name := range []string{"feature", "feature/x", "fix-123", "v1.0", "user@host"} {
+		assert.NoError(checkRefName(name), name)
+	}
+	for _, name := range []string{"", "@", "-x", "/x", "x/", "x.", "a..b", "a//b", "a@{1}",
+		"HEAD", "a b", "a~1", "a^", "a:b", "a?", "a*", "a[", "a\\b", ".hidden", "a/.b", "x.lock", "a.lock/b"} {
+		assert.Error(checkRefName(name), name)
+	}
+}
+
+func TestRenameBranch(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("feature", r.commit("initial", "README", "hello\n"))
+	assert.NoError(setUpstream(r.repo, "feature", "origin", "refs/heads/feature"))
+	r.checkout("feature")
+
+	renamed, err := renameBranch(r.repo, r.branches()["feature"], "feature/renamed")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal("feature/renamed", renamed.Name)
+
+	brs := r.branches()
+	assert.NotContains(brs, "feature")
+	assert.Contains(brs, "feature/renamed")
+
+	head, err := r.repo.Storer.Reference(plumbing.HEAD)
+	assert.NoError(err)
+	assert.Equal(renamed.Ref, head.Target())
+
+	up, ok := upstream(r.repo, "feature/renamed")
+	assert.True(ok)
+	assert.Equal(plumbing.ReferenceName("refs/remotes/origin/feature"), up)
+	_, ok = upstream(r.repo, "feature")
+	assert.False(ok)
+
+	back, err := renameBranch(r.repo, renamed, "feature")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Contains(r.branches(), "feature")
+	renamed, err = renameBranch(r.repo, back, "feature/renamed")
+	assert.NoError(err)
+
+	_, err = renameBranch(r.repo, renamed, "master")
+	assert.Error(err)
+	_, err = renameBranch(r.repo, renamed, "bad..name")
+	assert.Error(err)
+}
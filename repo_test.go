@@ -0,0 +1,132 @@
+package gitbr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// testRepo is a throwaway repository on disk used by the tests.
+type testRepo struct {
+	t    *testing.T
+	path string
+	repo *git.Repository
+	w    *git.Worktree
+	when time.Time
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	path, err := ioutil.TempDir("", "git-br")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := git.PlainInit(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testRepo{t, path, repo, w, time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+// noUserConfig points XDG_CONFIG_HOME at an empty directory for the rest of
+// the test, so the user config file of whoever runs it is not read. It
+// returns the directory.
+func noUserConfig(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	return home
+}
+
+func (r *testRepo) close() {
+	os.RemoveAll(r.path)
+}
+
+func (r *testRepo) write(name, content string) {
+	path := filepath.Join(r.path, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func (r *testRepo) read(name string) string {
+	content, err := ioutil.ReadFile(filepath.Join(r.path, name))
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return string(content)
+}
+
+// commit writes the given files and commits them on the current branch.
+func (r *testRepo) commit(msg string, files ...string) plumbing.Hash {
+	return r.commitWithParents(msg, nil, files...)
+}
+
+// merge records a merge of other into the current branch, keeping the tree.
+func (r *testRepo) merge(msg string, other plumbing.Hash) plumbing.Hash {
+	head, err := r.repo.Head()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return r.commitWithParents(msg, []plumbing.Hash{head.Hash(), other})
+}
+
+func (r *testRepo) commitWithParents(msg string, parents []plumbing.Hash, files ...string) plumbing.Hash {
+	for i := 0; i+1 < len(files); i += 2 {
+		r.write(files[i], files[i+1])
+		if _, err := r.w.Add(files[i]); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.when = r.when.Add(time.Hour)
+	h, err := r.w.Commit(msg, &git.CommitOptions{
+		Author:  &object.Signature{Name: "gopher", Email: "gopher@example.com", When: r.when},
+		Parents: parents,
+	})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return h
+}
+
+func (r *testRepo) branch(name string, h plumbing.Hash) {
+	ref := plumbing.NewHashReference(plumbing.ReferenceName("refs/heads/"+name), h)
+	if err := r.repo.Storer.SetReference(ref); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func (r *testRepo) checkout(name string) {
+	err := r.w.Checkout(&git.CheckoutOptions{Branch: plumbing.ReferenceName("refs/heads/" + name)})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func (r *testRepo) branches() Branches {
+	brs, err := extract(r.repo)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return brs
+}
+
+// identity sets the user of the repository git config, for the git binary.
+func (r *testRepo) identity(name, email string) {
+	for key, value := range map[string]string{"user.name": name, "user.email": email} {
+		if _, err := runGit(r.path, "config", key, value); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+}
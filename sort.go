@@ -0,0 +1,155 @@
+package gitbr
+
+import (
+	"bufio"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+)
+
+// sortMode is an order of the branch list, set with the gitbr.sort setting.
+type sortMode string
+
+const (
+	byAuthorDate    sortMode = "authordate"
+	byCommitterDate sortMode = "committerdate"
+	byName          sortMode = "name"
+	byAuthor        sortMode = "author"
+	byDivergence    sortMode = "divergence"
+	byCheckout      sortMode = "checkout"
+)
+
+// sortModes are the orders in the order they are cycled through.
+var sortModes = []sortMode{byAuthorDate, byCommitterDate, byName, byAuthor, byDivergence, byCheckout}
+
+func (m sortMode) String() string {
+	switch m {
+	case byCommitterDate:
+		return "committer date"
+	case byName:
+		return "name"
+	case byAuthor:
+		return "author"
+	case byDivergence:
+		return "ahead/behind the base"
+	case byCheckout:
+		return "last checkout"
+	}
+	return "author date"
+}
+
+// next returns the mode after m.
+func (m sortMode) next() sortMode {
+	for i, mode := range sortModes {
+		if mode == m {
+			return sortModes[(i+1)%len(sortModes)]
+		}
+	}
+	return sortModes[0]
+}
+
+// saveSortMode writes the mode to the gitbr.sort git config entry so the
+// next run starts with it.
+func saveSortMode(repo *git.Repository, mode sortMode) error {
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	cfg.Raw.Section(gitbrSection).SetOption("sort", string(mode))
+	return repo.Storer.SetConfig(cfg)
+}
+
+// sortBranches returns the branches in the given order. Ties, and the
+// branches never checked out when sorting by last checkout, are sorted by
+// author date, newest first.
+func sortBranches(brs Branches, mode sortMode, checkouts map[string]time.Time) []*Branch {
+	list := brs.Sorted()
+	var less func(a, b *Branch) bool
+	switch mode {
+	case byCommitterDate:
+		less = func(a, b *Branch) bool { return a.Commit.Committer.When.After(b.Commit.Committer.When) }
+	case byName:
+		less = func(a, b *Branch) bool { return a.Name < b.Name }
+	case byAuthor:
+		less = func(a, b *Branch) bool { return strings.ToLower(a.Author.Name) < strings.ToLower(b.Author.Name) }
+	case byDivergence:
+		// most ahead first, then least behind, the ones without base last
+		less = func(a, b *Branch) bool {
+			switch {
+			case a.Base == nil || b.Base == nil:
+				return a.Base != nil && b.Base == nil
+			case a.Base.Ahead != b.Base.Ahead:
+				return a.Base.Ahead > b.Base.Ahead
+			}
+			return a.Base.Behind < b.Base.Behind
+		}
+	case byCheckout:
+		less = func(a, b *Branch) bool {
+			ta, oka := checkouts[a.Name]
+			tb, okb := checkouts[b.Name]
+			if !oka || !okb {
+				return oka && !okb
+			}
+			return ta.After(tb)
+		}
+	default:
+		return list
+	}
+	sort.SliceStable(list, func(i, j int) bool { return less(list[i], list[j]) })
+	return list
+}
+
+// lastCheckouts returns when every branch was last checked out, from the
+// "checkout: moving from a to b" entries of the HEAD reflog.
+func lastCheckouts(repo *git.Repository) (map[string]time.Time, error) {
+	checkouts := make(map[string]time.Time)
+	s, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return checkouts, nil
+	}
+	f, err := s.Filesystem().Open("logs/HEAD")
+	if err != nil {
+		// no reflog, nothing was ever checked out
+		return checkouts, nil
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, when, ok := parseCheckout(scanner.Text())
+		if ok && when.After(checkouts[name]) {
+			checkouts[name] = when
+		}
+	}
+	return checkouts, scanner.Err()
+}
+
+// parseCheckout returns the branch checked out and when from a reflog line
+// like "<old> <new> Name <email> 1496318400 +0200\tcheckout: moving from a to b".
+func parseCheckout(line string) (string, time.Time, bool) {
+	parts := strings.SplitN(line, "\t", 2)
+	if len(parts) != 2 {
+		return "", time.Time{}, false
+	}
+	const prefix = "checkout: moving from "
+	if !strings.HasPrefix(parts[1], prefix) {
+		return "", time.Time{}, false
+	}
+	i := strings.LastIndex(parts[1], " to ")
+	if i < 0 {
+		return "", time.Time{}, false
+	}
+	fields := strings.Fields(parts[0])
+	if len(fields) < 2 {
+		return "", time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(fields[len(fields)-2], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return parts[1][i+len(" to "):], time.Unix(sec, 0), true
+}
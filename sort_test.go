@@ -0,0 +1,63 @@
+package gitbr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortBranches(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.branch("b-old", r.commit("initial", "README", "hello\n"))
+	r.branch("c-ahead", r.commit("second", "README", "hello world\n"))
+	r.checkout("c-ahead")
+	r.commit("third", "c.txt", "c\n")
+	r.commit("fourth", "c.txt", "cc\n")
+	r.checkout("master")
+	r.branch("a-new", r.commit("fifth", "a.txt", "a\n"))
+
+	brs := r.branches()
+	NewInventory(r.repo).Compare(brs, brs["master"])
+
+	assert.Equal([]string{"a-new", "master", "c-ahead", "b-old"}, names(sortBranches(brs, byAuthorDate, nil)))
+	assert.Equal([]string{"a-new", "b-old", "c-ahead", "master"}, names(sortBranches(brs, byName, nil)))
+	assert.Equal([]string{"c-ahead", "a-new", "b-old", "master"}, names(sortBranches(brs, byDivergence, nil)))
+
+	checkouts := map[string]time.Time{"b-old": time.Unix(2, 0), "c-ahead": time.Unix(1, 0)}
+	assert.Equal([]string{"b-old", "c-ahead", "a-new", "master"}, names(sortBranches(brs, byCheckout, checkouts)))
+}
+
+func TestSortMode(t *testing.T) {
+	assert := assert.New(t)
+	noUserConfig(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	assert.NoError(saveSortMode(r.repo, byAuthorDate.next()))
+	s, err := loadSettings(r.repo)
+	assert.NoError(err)
+	assert.Equal(byCommitterDate, s.sort)
+	assert.Equal(byAuthorDate, byCheckout.next())
+}
+
+func TestLastCheckouts(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	r.write(".git/logs/HEAD", ""+
+		"0000000000000000000000000000000000000000 1111111111111111111111111111111111111111 A U Thor <a@example.com> 100 +0200\tcommit (initial): initial\n"+
+		"1111111111111111111111111111111111111111 1111111111111111111111111111111111111111 A U Thor <a@example.com> 200 +0200\tcheckout: moving from master to feature\n"+
+		"1111111111111111111111111111111111111111 1111111111111111111111111111111111111111 A U Thor <a@example.com> 300 -0100\tcheckout: moving from feature to master\n"+
+		"1111111111111111111111111111111111111111 1111111111111111111111111111111111111111 A U Thor <a@example.com> 400 +0000\tcheckout: moving from master to feature\n")
+
+	checkouts, err := lastCheckouts(r.repo)
+	assert.NoError(err)
+	assert.Len(checkouts, 2)
+	assert.Equal(int64(400), checkouts["feature"].Unix())
+	assert.Equal(int64(300), checkouts["master"].Unix())
+}
@@ -0,0 +1,156 @@
+package gitbr
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+)
+
+// stash is an entry of the stash, stash@{Index}. Its commit holds the
+// worktree changes on top of the commit they were made on, its first parent.
+type stash struct {
+	Index   int
+	Hash    plumbing.Hash
+	Branch  string
+	Message string
+	When    time.Time
+}
+
+func (s stash) ref() string {
+	return fmt.Sprintf("stash@{%d}", s.Index)
+}
+
+// readStashes returns the stashes, the newest first, from the reflog of
+// refs/stash.
+func readStashes(repo *git.Repository) ([]stash, error) {
+	s, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return nil, nil
+	}
+	f, err := s.Filesystem().Open("logs/refs/stash")
+	if os.IsNotExist(err) {
+		// no reflog, nothing was ever stashed
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var stashes []stash
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if st, ok := parseStash(scanner.Text()); ok {
+			stashes = append(stashes, st)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	// the reflog is oldest first, stash@{0} is its last line
+	for i, j := 0, len(stashes)-1; i < j; i, j = i+1, j-1 {
+		stashes[i], stashes[j] = stashes[j], stashes[i]
+	}
+	for i := range stashes {
+		stashes[i].Index = i
+	}
+	return stashes, nil
+}
+
+// parseStash reads a reflog line of refs/stash like
+// "<old> <new> Name <email> 1496318400 +0200\tWIP on master: abc1234 subject".
+func parseStash(line string) (stash, bool) {
+	parts := strings.SplitN(line, "\t", 2)
+	if len(parts) != 2 {
+		return stash{}, false
+	}
+	fields := strings.Fields(parts[0])
+	if len(fields) < 4 {
+		return stash{}, false
+	}
+	sec, err := strconv.ParseInt(fields[len(fields)-2], 10, 64)
+	if err != nil {
+		return stash{}, false
+	}
+	branch, msg := stashBranch(parts[1])
+	return stash{
+		Hash:    plumbing.NewHash(fields[1]),
+		Branch:  branch,
+		Message: msg,
+		When:    time.Unix(sec, 0),
+	}, true
+}
+
+// stashBranch splits a stash message, "WIP on master: abc1234 subject" or
+// "On master: message", into the branch it was made on and the rest.
+func stashBranch(msg string) (string, string) {
+	for _, prefix := range []string{"WIP on ", "On "} {
+		if !strings.HasPrefix(msg, prefix) {
+			continue
+		}
+		rest := msg[len(prefix):]
+		if i := strings.Index(rest, ": "); i >= 0 {
+			return rest[:i], rest[i+2:]
+		}
+	}
+	return "", msg
+}
+
+// stashChanges returns the changes kept by a stash: the ones of the tracked
+// files, against the commit it was made on, and the untracked files stashed
+// with --include-untracked, in its third parent, as added. It gives up with
+// the error of ctx once it is done.
+func (inv *Inventory) stashChanges(ctx context.Context, c *object.Commit) (object.Changes, error) {
+	changes, err := inv.CommitChanges(c)
+	if err != nil || len(c.ParentHashes) < 3 {
+		return changes, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	untracked, err := inv.repo.CommitObject(c.ParentHashes[2])
+	if err != nil {
+		return nil, err
+	}
+	tree, err := untracked.Tree()
+	if err != nil {
+		return nil, err
+	}
+	added, err := inv.diffTree(nil, tree)
+	if err != nil {
+		return nil, err
+	}
+	// the cached changes are not appended to in place
+	return append(append(object.Changes{}, changes...), added...), nil
+}
+
+// stashesOn returns the stashes made on the branch.
+func stashesOn(stashes []stash, branch string) []stash {
+	var on []stash
+	for _, s := range stashes {
+		if s.Branch == branch {
+			on = append(on, s)
+		}
+	}
+	return on
+}
+
+// runStash runs git stash apply, pop or drop on s. As stashes are numbered
+// from the newest, it fails if s is no longer at its index.
+func runStash(path, cmd string, s stash) error {
+	h, err := runGit(path, "rev-parse", "--verify", "--quiet", "refs/"+s.ref())
+	if err != nil || h != s.Hash.String() {
+		return fmt.Errorf("%s moved, read the stashes again", s.ref())
+	}
+	_, err = runGit(path, "stash", cmd, s.ref())
+	return err
+}
@@ -0,0 +1,99 @@
+package gitbr
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStashBranch(t *testing.T) {
+	assert := assert.New(t)
+
+	for msg, want := range map[string][2]string{
+		"WIP on master: abc1234 subject":              {"master", "abc1234 subject"},
+		"On feature/x: git-br: before switching to y": {"feature/x", "git-br: before switching to y"},
+		"WIP on (no branch): abc1234 subject":         {"(no branch)", "abc1234 subject"},
+		"something else":                              {"", "something else"},
+	} {
+		branch, rest := stashBranch(msg)
+		assert.Equal(want[0], branch, msg)
+		assert.Equal(want[1], rest, msg)
+	}
+
+	s, ok := parseStash("0000000000000000000000000000000000000000 1111111111111111111111111111111111111111 A U Thor <a@example.com> 1496318400 +0200\tOn master: wip")
+	assert.True(ok)
+	assert.Equal("master", s.Branch)
+	assert.Equal("1111111111111111111111111111111111111111", s.Hash.String())
+	assert.Equal(int64(1496318400), s.When.Unix())
+	_, ok = parseStash("garbage")
+	assert.False(ok)
+}
+
+func TestStashes(t *testing.T) {
+	assert := assert.New(t)
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git binary")
+	}
+	r := newTestRepo(t)
+	defer r.close()
+	r.identity("Stasher", "stasher@example.com")
+
+	r.commit("initial", "README", "hello\n")
+	stashes, err := readStashes(r.repo)
+	assert.NoError(err)
+	assert.Empty(stashes)
+
+	r.write("README", "first\n")
+	_, err = runGit(r.path, "stash", "push", "-m", "first")
+	assert.NoError(err)
+	r.branch("feature", r.branches()["master"].Commit.Hash)
+	r.checkout("feature")
+	r.write("README", "second\n")
+	_, err = runGit(r.path, "stash", "push", "-m", "second")
+	assert.NoError(err)
+
+	stashes, err = readStashes(r.repo)
+	assert.NoError(err)
+	if !assert.Len(stashes, 2) {
+		return
+	}
+	assert.Equal("stash@{0}", stashes[0].ref())
+	assert.Equal("feature", stashes[0].Branch)
+	assert.Equal("second", stashes[0].Message)
+	assert.Equal("master", stashes[1].Branch)
+	assert.Len(stashesOn(stashes, "master"), 1)
+
+	// the diff of a stash is the one against the commit it was made on
+	c, err := r.repo.CommitObject(stashes[1].Hash)
+	assert.NoError(err)
+	changes, err := NewInventory(r.repo).CommitChanges(c)
+	assert.NoError(err)
+	assert.Len(changes, 1)
+
+	assert.NoError(runStash(r.path, "apply", stashes[1]))
+	assert.Equal("first\n", r.read("README"))
+	assert.NoError(runStash(r.path, "drop", stashes[0]))
+	// stash@{1} is now stash@{0}
+	assert.Error(runStash(r.path, "pop", stashes[1]))
+	stashes, err = readStashes(r.repo)
+	assert.NoError(err)
+	assert.Len(stashes, 1)
+
+	// the untracked files stashed are shown as added
+	r.write("notes.txt", "untracked\n")
+	_, err = runGit(r.path, "stash", "push", "--include-untracked", "-m", "untracked")
+	assert.NoError(err)
+	stashes, err = readStashes(r.repo)
+	assert.NoError(err)
+	c, err = r.repo.CommitObject(stashes[0].Hash)
+	assert.NoError(err)
+	changes, err = NewInventory(r.repo).stashChanges(context.Background(), c)
+	assert.NoError(err)
+	var names []string
+	for _, change := range changes {
+		names = append(names, change.To.Name)
+	}
+	assert.Equal([]string{"README", "notes.txt"}, names)
+}
@@ -0,0 +1,99 @@
+package gitbr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/log"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// Annotation holds what an annotated tag adds to the commit it points to.
+type Annotation struct {
+	Tagger  object.Signature
+	Message string
+}
+
+// isTag reports whether b is a tag rather than a branch.
+func (b Branch) isTag() bool {
+	return strings.HasPrefix(string(b.Ref), "refs/tags/")
+}
+
+// extractTags returns the tags pointing to commits, refs/tags/*, as branches
+// at the tagged commit. The annotated ones have their tagger as author.
+func extractTags(repo *git.Repository) (Branches, error) {
+	refs, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(Branches)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		var ann *Annotation
+		commit, err := repo.CommitObject(ref.Hash())
+		if err == plumbing.ErrObjectNotFound {
+			tag, err := repo.TagObject(ref.Hash())
+			if err != nil {
+				log.Error(err.Error())
+				return nil
+			}
+			ann = &Annotation{Tagger: tag.Tagger, Message: tag.Message}
+			// tags of trees or blobs are skipped
+			if commit, err = tag.Commit(); err != nil {
+				return nil
+			}
+		} else if err != nil {
+			log.Error(err.Error())
+			return nil
+		}
+		tree, err := commit.Tree()
+		if err != nil {
+			log.Error(err.Error())
+			return nil
+		}
+		name := ref.Name()
+		t := &Branch{
+			Name:       name.Short(),
+			Author:     commit.Author,
+			Ref:        name,
+			Commit:     commit,
+			Tree:       tree,
+			Annotation: ann,
+		}
+		if ann != nil {
+			t.Author = ann.Tagger
+		}
+		tags[t.Name] = t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// createTag creates an annotated tag of the tip of from. go-git cannot, so it
+// relies on the git binary, which also knows who the tagger is.
+func createTag(path, name string, from *Branch, message string) error {
+	if err := checkRefName(name); err != nil {
+		return err
+	}
+	if strings.TrimSpace(message) == "" {
+		return fmt.Errorf("annotated tags need a message")
+	}
+	_, err := runGit(path, "tag", "-a", name, "-m", message, from.Commit.Hash.String())
+	return err
+}
+
+// tagDetails returns the tagger, date and message of an annotated tag, an
+// empty string for anything else.
+func tagDetails(b *Branch) string {
+	if b.Annotation == nil {
+		return ""
+	}
+	a := b.Annotation
+	return fmt.Sprintf("tag %s by %s <%s> on %s\n\n    %s\n\n", b.Name, a.Tagger.Name, a.Tagger.Email,
+		a.Tagger.When.Format("2006-01-02 15:04"), strings.Replace(strings.TrimSpace(a.Message), "\n", "\n    ", -1))
+}
@@ -0,0 +1,69 @@
+package gitbr
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestTags(t *testing.T) {
+	assert := assert.New(t)
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git binary")
+	}
+	r := newTestRepo(t)
+	defer r.close()
+	r.identity("Tagger", "tagger@example.com")
+
+	first := r.commit("initial", "README", "hello\n")
+	second := r.commit("second", "README", "bye\n")
+	assert.NoError(r.repo.Storer.SetReference(plumbing.NewHashReference("refs/tags/v0", first)))
+	master := r.branches()["master"]
+	assert.NoError(createTag(r.path, "v1", master, "first release\n\nwith notes"))
+	assert.Error(createTag(r.path, "v2", master, " "))
+	assert.Error(createTag(r.path, "no spaces", master, "message"))
+
+	tags, err := extractTags(r.repo)
+	assert.NoError(err)
+	assert.Len(tags, 2)
+	v0, v1 := tags["v0"], tags["v1"]
+	if !assert.NotNil(v0) || !assert.NotNil(v1) {
+		return
+	}
+	assert.True(v0.isTag())
+	assert.Nil(v0.Annotation)
+	assert.Equal(first, v0.Commit.Hash)
+	assert.Equal("", tagDetails(v0))
+	assert.Contains(v0.String(), "t v0")
+
+	assert.Equal(second, v1.Commit.Hash)
+	if assert.NotNil(v1.Annotation) {
+		assert.Equal("Tagger", v1.Author.Name)
+		assert.Contains(v1.Annotation.Message, "first release")
+	}
+	assert.Contains(tagDetails(v1), "tag v1 by Tagger <tagger@example.com>")
+	assert.Contains(tagDetails(v1), "    with notes")
+}
+
+func TestCheckoutTagDetaches(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	first := r.commit("initial", "README", "hello\n")
+	r.commit("second", "README", "bye\n")
+	assert.NoError(r.repo.Storer.SetReference(plumbing.NewHashReference("refs/tags/v0", first)))
+	tags, err := extractTags(r.repo)
+	assert.NoError(err)
+
+	plan, err := planCheckout(r.repo, tags["v0"])
+	assert.NoError(err)
+	assert.NoError(checkout(r.repo, r.path, plan))
+	head, err := r.repo.Head()
+	assert.NoError(err)
+	assert.Equal(plumbing.HEAD, head.Name())
+	assert.Equal(first, head.Hash())
+	assert.Equal("hello\n", r.read("README"))
+}
@@ -0,0 +1,1062 @@
+package gitbr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/marcusolsson/tui-go"
+	runewidth "github.com/mattn/go-runewidth"
+	"github.com/ryanuber/columnize"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// tuiUI holds the widgets and the state of the interactive branch browser.
+type tuiUI struct {
+	tui.UI
+
+	inv      *Inventory
+	repo     *git.Repository
+	path     string
+	base     string
+	settings *settings
+
+	brs         Branches
+	remotes     Branches
+	showRemotes bool
+	tags        Branches
+	showTags    bool
+	twoDot      bool
+	sort        sortMode
+	sortedBrs   []*Branch
+	// filter is the fuzzy pattern typed after /, shown the branches of
+	// sortedBrs matching it, in the order of the list items.
+	filter string
+	shown  []*Branch
+
+	root     *tui.Box
+	bottom   *tui.Box
+	list     *branchList
+	diffView *tui.Label
+	diffBox  *tui.Box
+	// pane is what the right pane shows below diffView. The files and log
+	// panes list changes and commits, tab moves the focus to them and enter
+	// opens the diff of an item in place, until esc.
+	pane        paneMode
+	sideFocused bool
+	opened      bool
+	files       *tui.List
+	filesBox    *tui.Box
+	changes     []*object.Change
+	log         *tui.List
+	logBox      *tui.Box
+	commits     []*object.Commit
+	stashList   *tui.List
+	stashBox    *tui.Box
+	stashes     []stash
+	// stashFilter keeps the stashes made on the selected branch only.
+	stashFilter bool
+	// patch draws the diffs, scrolled patchTop lines down.
+	patch     *patchView
+	patchArea *tui.ScrollArea
+	patchTop  int
+	// differ computes what the right pane shows. Only the results of the
+	// job numbered diffGen are shown, the others are stale. diffShown are
+	// the commits of the branch and the base branch shown.
+	differ    *differ
+	diffGen   int
+	diffShown hashPair
+	status    *tui.StatusBar
+
+	// stale is set when the refs changed while an entry or a question was
+	// open, to refresh the branches once it is closed.
+	stale bool
+
+	// keys maps the keys, as normalized by keyName, to their handlers.
+	keys map[string]func()
+	// count is the number typed before an action, 0 if none.
+	count  int
+	prompt map[string]func()
+	entry  *tui.Entry
+	// onCancel is run when the input is closed with Esc.
+	onCancel func()
+}
+
+func newTuiUI(inv *Inventory, loc *location, brs Branches, base string, s *settings, d *differ) tui.UI {
+	u := &tuiUI{
+		inv:      inv,
+		differ:   d,
+		repo:     inv.Repository(),
+		path:     loc.workTree,
+		base:     base,
+		settings: s,
+		brs:      brs,
+		sort:     s.sort,
+		keys:     make(map[string]func()),
+	}
+
+	inv.Compare(brs, brs[base])
+	u.list = &branchList{List: tui.NewList()}
+	u.list.SetFocused(true)
+
+	u.diffView = tui.NewLabel("")
+
+	u.status = tui.NewStatusBar("")
+	u.status.SetText("[press enter to switch to selected branch]")
+	u.setHelp()
+	u.patch = &patchView{}
+	u.patchArea = tui.NewScrollArea(u.patch)
+	u.files = tui.NewList()
+	u.files.OnItemActivated(func(*tui.List) { u.activateSide() })
+	u.filesBox = tui.NewVBox(u.files, tui.NewSpacer())
+	u.log = tui.NewList()
+	u.log.OnItemActivated(func(*tui.List) { u.activateSide() })
+	u.logBox = tui.NewVBox(u.log, tui.NewSpacer())
+	u.stashList = tui.NewList()
+	u.stashList.OnItemActivated(func(*tui.List) { u.activateSide() })
+	u.stashBox = tui.NewVBox(u.stashList, tui.NewSpacer())
+	u.diffBox = tui.NewVBox(u.diffView, u.filesBox)
+	u.diffBox.SetBorder(true)
+	tableBox := tui.NewVBox(u.list, tui.NewSpacer())
+	tableBox.SetBorder(true)
+	top := tui.NewHBox(tableBox, u.diffBox)
+	header := tui.NewLabel("repository: " + loc.String())
+	u.bottom = tui.NewVBox(u.status)
+	u.root = tui.NewVBox(
+		header,
+		top,
+		u.bottom,
+	)
+
+	th := tui.NewTheme()
+	for name, style := range s.theme {
+		th.SetStyle(name, style)
+	}
+
+	u.UI = tui.New(&keyRouter{Widget: u.root, u: u})
+	u.SetTheme(th)
+	u.bind("Esc", func() {
+		if u.opened {
+			u.closePatch()
+			return
+		}
+		if u.sideFocused {
+			u.focusSide(false)
+			return
+		}
+		if u.filter != "" {
+			u.setFilter("")
+			u.status.SetText("filter cleared")
+			return
+		}
+		u.Quit()
+	})
+	u.bindActions()
+	u.list.OnSelectionChanged(func(l *tui.List) {
+		u.cancelPrompt()
+		if br := u.selected(); br != nil {
+			u.showChanges(br)
+		}
+	})
+	u.resort()
+	if s.live {
+		refresh := func() { u.Update(u.liveRefresh) }
+		watchRefs(loc.commonDir, refresh)
+		if loc.gitDir != loc.commonDir {
+			// the HEAD of a linked worktree is in its own directory
+			watchRefs(loc.gitDir, refresh)
+		}
+	}
+
+	return u
+}
+
+func listItems(sortedBrs []*Branch, s *settings) []string {
+	var brStr []string
+	for _, br := range sortedBrs {
+		brStr = append(brStr, br.row(s.authorWidth, s.nameWidth))
+	}
+	return strings.Split(columnize.SimpleFormat(brStr), "\n")
+}
+
+// branchList is a tui.List that highlights the runes of its items matched by
+// the filter and the items of the protected branches.
+type branchList struct {
+	*tui.List
+	items     []string
+	matched   []map[int]bool
+	protected []bool
+}
+
+func (l *branchList) setItems(items []string, matched []map[int]bool, protected []bool) {
+	l.RemoveItems()
+	l.AddItems(items...)
+	l.items, l.matched, l.protected = items, matched, protected
+}
+
+// Draw draws the items like tui.List does, rune by rune so the matched ones
+// get their own style.
+func (l *branchList) Draw(p *tui.Painter) {
+	for i, item := range l.items {
+		style := "list.item"
+		if i == l.Selected() {
+			style += ".selected"
+		}
+		rowStyle := style
+		if l.protected[i] && i != l.Selected() {
+			rowStyle += ".protected"
+		}
+		p.WithStyle(rowStyle, func(p *tui.Painter) {
+			p.FillRect(0, i, l.Size().X, 1)
+		})
+		x := 0
+		for j, r := range []rune(item) {
+			runeStyle := rowStyle
+			if l.matched[i][j] {
+				runeStyle = style + ".match"
+			}
+			p.WithStyle(runeStyle, func(p *tui.Painter) {
+				p.DrawRune(x, i, r)
+			})
+			x += runewidth.RuneWidth(r)
+		}
+	}
+}
+
+// selected returns the branch under the list cursor, if any.
+func (u *tuiUI) selected() *Branch {
+	i := u.list.Selected()
+	if i < 0 || i >= len(u.shown) {
+		return nil
+	}
+	return u.shown[i]
+}
+
+// refresh extracts the branches again and rebuilds the list, keeping the
+// cursor on the same line when possible.
+func (u *tuiUI) refresh() error {
+	brs, err := u.inv.Branches()
+	if err != nil {
+		return err
+	}
+	u.inv.Compare(brs, brs[u.base])
+	u.brs = brs
+	if u.showRemotes {
+		remotes, err := u.inv.Remotes()
+		if err != nil {
+			return err
+		}
+		u.inv.Compare(remotes, brs[u.base])
+		u.remotes = remotes
+	}
+	if u.showTags {
+		tags, err := u.inv.Tags()
+		if err != nil {
+			return err
+		}
+		u.inv.Compare(tags, brs[u.base])
+		u.tags = tags
+	}
+
+	u.resort()
+	return nil
+}
+
+// resort sorts the branches again in the current mode and rebuilds the list.
+func (u *tuiUI) resort() {
+	u.sortedBrs = u.sortBranches(u.brs)
+	if u.showRemotes {
+		// remote-tracking branches go in their own section after the local ones
+		u.sortedBrs = append(u.sortedBrs, u.sortBranches(u.remotes)...)
+	}
+	if u.showTags {
+		u.sortedBrs = append(u.sortedBrs, u.sortBranches(u.tags)...)
+	}
+	u.fill()
+}
+
+func (u *tuiUI) sortBranches(brs Branches) []*Branch {
+	var checkouts map[string]time.Time
+	if u.sort == byCheckout {
+		var err error
+		if checkouts, err = lastCheckouts(u.repo); err != nil {
+			u.status.SetText(err.Error())
+		}
+	}
+	return sortBranches(brs, u.sort, checkouts)
+}
+
+// cycleSort switches to the next sort mode, saved on exit by Run.
+func (u *tuiUI) cycleSort() {
+	u.sort = u.sort.next()
+	u.resort()
+	u.setHelp()
+	u.status.SetText("sorted by " + u.sort.String())
+}
+
+// Run runs the UI until it quits, then saves the sort mode in the repository
+// git config if it was changed, for the next runs to start with it.
+func (u *tuiUI) Run() error {
+	err := u.UI.Run()
+	if u.sort == u.settings.sort {
+		return err
+	}
+	if serr := saveSortMode(u.repo, u.sort); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// setHelp lists the actions with a short name in the status bar.
+func (u *tuiUI) setHelp() {
+	var help []string
+	for _, a := range actions {
+		if a.short != "" {
+			help = append(help, u.settings.keys[a.name]+" "+a.short)
+		}
+	}
+	u.status.SetPermanentText(fmt.Sprintf("[sorted by %s] [%s, esc or %s to quit]", u.sort, strings.Join(help, ", "), u.settings.keys["quit"]))
+}
+
+// fill rebuilds the list items from the sorted branches matching the
+// filter, keeping the cursor on the same branch when it is still shown. The
+// right pane is only computed again if the branch or the base branch moved.
+func (u *tuiUI) fill() {
+	prev, selected := u.selected(), u.list.Selected()
+	u.shown = nil
+	var items []string
+	var matched []map[int]bool
+	var protected []bool
+	if len(u.sortedBrs) > 0 {
+		// rows are made from all the branches so the columns do not move
+		// while typing
+		rows := listItems(u.sortedBrs, u.settings)
+		for i, br := range u.sortedBrs {
+			m, ok := matchBranch(u.filter, br)
+			if !ok {
+				continue
+			}
+			u.shown = append(u.shown, br)
+			items = append(items, rows[i])
+			matched = append(matched, highlights(rows[i], br, m, u.settings))
+			protected = append(protected, u.isProtected(br))
+		}
+	}
+	u.list.setItems(items, matched, protected)
+	if len(u.shown) == 0 {
+		u.stopDiff()
+		u.diffShown = hashPair{}
+		u.diffView.SetText("")
+		u.setPatch(nil)
+		return
+	}
+	same := false
+	for i, br := range u.shown {
+		if prev != nil && br.Ref == prev.Ref {
+			selected = i
+			same = u.diffKey(br) == u.diffShown
+		}
+	}
+	if selected >= len(u.shown) {
+		selected = len(u.shown) - 1
+	}
+	if selected < 0 {
+		selected = 0
+	}
+	if same {
+		u.list.SetSelected(selected)
+		return
+	}
+	u.list.Select(selected)
+}
+
+// isProtected reports whether br is a local branch matching the protected
+// patterns.
+func (u *tuiUI) isProtected(br *Branch) bool {
+	return br.Remote == "" && !br.isTag() && isProtected(br.Name, u.settings.protectedPatterns())
+}
+
+// diffKey returns the commits the right pane of br is computed from.
+func (u *tuiUI) diffKey(br *Branch) hashPair {
+	key := hashPair{a: br.Commit.Hash}
+	if base, ok := u.brs[u.base]; ok {
+		key.b = base.Commit.Hash
+	}
+	return key
+}
+
+// liveRefresh reads the branches again after their refs changed, unless an
+// entry or a question is open, which would be cancelled.
+func (u *tuiUI) liveRefresh() {
+	if u.entry != nil || u.prompt != nil {
+		u.stale = true
+		return
+	}
+	u.stale = false
+	if err := u.refresh(); err != nil {
+		u.status.SetText(err.Error())
+	}
+}
+
+// startFilter opens an entry that filters the list while typing. Enter keeps
+// the filter, Esc clears it.
+func (u *tuiUI) startFilter() {
+	u.input("/", u.filter, func(string) {
+		u.status.SetText(fmt.Sprintf("%d of %d branches match %s, esc to clear", len(u.shown), len(u.sortedBrs), u.filter))
+	})
+	u.entry.OnChanged(func(e *tui.Entry) { u.setFilter(e.Text()) })
+	u.onCancel = func() { u.setFilter("") }
+}
+
+func (u *tuiUI) setFilter(pattern string) {
+	u.filter = strings.TrimSpace(pattern)
+	u.fill()
+}
+
+func (u *tuiUI) toggleRemotes() {
+	u.showRemotes = !u.showRemotes
+	if err := u.refresh(); err != nil {
+		u.status.SetText(err.Error())
+		return
+	}
+	if u.showRemotes {
+		u.status.SetText(fmt.Sprintf("showing %d remote-tracking branches after the local ones", len(u.remotes)))
+	} else {
+		u.status.SetText("hiding remote-tracking branches")
+	}
+}
+
+func (u *tuiUI) toggleTags() {
+	u.showTags = !u.showTags
+	if err := u.refresh(); err != nil {
+		u.status.SetText(err.Error())
+		return
+	}
+	if u.showTags {
+		u.status.SetText(fmt.Sprintf("showing %d tags after the branches", len(u.tags)))
+	} else {
+		u.status.SetText("hiding tags")
+	}
+}
+
+// bind registers fn as the handler of a key. Keys are routed through
+// dispatch so that an open question in the status bar gets them first.
+func (u *tuiUI) bind(key string, fn func()) {
+	u.keys[keyName(key)] = fn
+}
+
+// dispatch runs the handler of a key, given by its tui.KeyEvent name, unless
+// an entry or a question takes it. Digits make the count of the next action.
+// It reports whether the key was used, for the widgets not to get it too.
+func (u *tuiUI) dispatch(key string) bool {
+	used := u.handleKey(key)
+	if u.stale {
+		u.liveRefresh()
+	}
+	return used
+}
+
+func (u *tuiUI) handleKey(key string) bool {
+	if u.entry != nil {
+		// keys are text while typing, only Esc gets out of the entry
+		if key != "Esc" {
+			return false
+		}
+		cancel := u.onCancel
+		u.closeInput()
+		if cancel != nil {
+			cancel()
+		}
+		u.status.SetText("cancelled")
+		return true
+	}
+	if u.prompt != nil {
+		fn, ok := u.prompt[key]
+		if !ok && key != "Esc" {
+			return false
+		}
+		u.prompt = nil
+		if fn != nil {
+			fn()
+		} else {
+			u.status.SetText("cancelled")
+		}
+		return true
+	}
+	if u.countKey(key) {
+		return true
+	}
+	if key == "Esc" && u.count > 0 {
+		u.count = 0
+		u.status.SetText("cancelled")
+		return true
+	}
+	fn := u.keys[keyName(key)]
+	if fn != nil {
+		fn()
+	}
+	u.count = 0
+	return fn != nil
+}
+
+// ask shows a question in the status bar and waits for one of the answer
+// keys. Esc or moving to another branch cancels it.
+func (u *tuiUI) ask(question string, answers map[string]func()) {
+	u.prompt = answers
+	u.status.SetText(question)
+}
+
+// input shows an entry above the status bar and calls fn with its text once
+// submitted. Esc closes it without calling fn.
+func (u *tuiUI) input(label, text string, fn func(string)) {
+	u.closeInput()
+
+	u.entry = tui.NewEntry()
+	u.entry.SetText(text)
+	u.entry.OnSubmit(func(e *tui.Entry) {
+		u.closeInput()
+		fn(strings.TrimSpace(e.Text()))
+	})
+	u.bottom.Insert(0, tui.NewHBox(tui.NewLabel(label+" "), u.entry))
+	u.blur()
+	u.entry.SetFocused(true)
+}
+
+func (u *tuiUI) closeInput() {
+	if u.entry == nil {
+		return
+	}
+	u.entry = nil
+	u.onCancel = nil
+	u.bottom.Remove(0)
+	u.refocus()
+}
+
+func (u *tuiUI) cancelPrompt() {
+	if u.prompt != nil {
+		u.prompt = nil
+		u.status.SetText("cancelled")
+	}
+}
+
+// switchBranch checks out br, asking what to do with the uncommitted changes
+// in the worktree, if any.
+func (u *tuiUI) switchBranch(br *Branch) {
+	if br.Remote != "" {
+		u.trackRemote(br)
+		return
+	}
+
+	plan, err := planCheckout(u.repo, br)
+	if err != nil {
+		u.status.SetText(err.Error())
+		return
+	}
+
+	if plan.clean() {
+		u.doSwitch(plan, checkout)
+		return
+	}
+
+	details := fmt.Sprintf("uncommitted changes:\n\n    %s", strings.Join(plan.dirty, "\n    "))
+	if !plan.canCarry() {
+		details += fmt.Sprintf("\n\nblocking the switch to %s:\n\n    %s", br.Name, strings.Join(plan.conflicts, "\n    "))
+	}
+	u.diffView.SetText(details)
+	answers := map[string]func(){
+		"s": func() { u.doSwitch(plan, stashAndCheckout) },
+		"a": func() { u.status.SetText("switch aborted") },
+	}
+	question := plan.String() + ": [s]tash them or [a]bort?"
+	if plan.canCarry() {
+		answers["c"] = func() { u.doSwitch(plan, checkout) }
+		carry := "[c]arry them over"
+		if len(plan.staged) > 0 {
+			carry += " unstaged"
+		}
+		question = plan.String() + ": " + carry + ", [s]tash them or [a]bort?"
+	}
+	u.ask(question, answers)
+}
+
+// trackRemote offers to create a local branch tracking the remote one and to
+// switch to it.
+func (u *tuiUI) trackRemote(remote *Branch) {
+	name := strings.TrimPrefix(remote.Name, remote.Remote+"/")
+	if _, ok := u.brs[name]; ok {
+		u.status.SetText(fmt.Sprintf("there is already a local %s branch", name))
+		return
+	}
+	u.ask(fmt.Sprintf("create local branch %s tracking %s and switch to it? [y/n]", name, remote.Name), map[string]func(){
+		"y": func() {
+			local, err := u.inv.Track(remote)
+			if err != nil {
+				u.status.SetText(err.Error())
+				return
+			}
+			if err := u.refresh(); err != nil {
+				u.status.SetText(err.Error())
+				return
+			}
+			u.switchBranch(local)
+		},
+		"n": func() { u.status.SetText("cancelled") },
+	})
+}
+
+func (u *tuiUI) doSwitch(plan *checkoutPlan, fn func(*git.Repository, string, *checkoutPlan) error) {
+	if err := fn(u.repo, u.path, plan); err != nil {
+		u.status.SetText(err.Error())
+		return
+	}
+	// read the branches again for the * to follow HEAD
+	if err := u.refresh(); err != nil {
+		u.status.SetText(err.Error())
+		return
+	}
+	if br := u.selected(); br != nil {
+		u.showChanges(br)
+	}
+	if plan.target.isTag() {
+		u.status.SetText(fmt.Sprintf("detached HEAD at %s (%s)", plan.target.Name, plan.target.Commit.Hash.String()[0:7]))
+	} else {
+		u.status.SetText("switched to " + plan.target.Name)
+	}
+}
+
+// deleteBranch removes br right away when it is fully merged into the base
+// branch or its upstream, and asks for confirmation otherwise.
+func (u *tuiUI) deleteBranch(br *Branch) {
+	if br == nil {
+		return
+	}
+	if br.Remote != "" {
+		u.status.SetText("remote-tracking branches cannot be deleted")
+		return
+	}
+	if br.isTag() {
+		u.status.SetText("tags cannot be deleted from here")
+		return
+	}
+	if u.isProtected(br) {
+		u.status.SetText(fmt.Sprintf("%s is protected and cannot be deleted", br.Name))
+		return
+	}
+	plan, err := u.inv.planDelete(u.brs, br, u.base)
+	if err != nil {
+		u.status.SetText(err.Error())
+		return
+	}
+
+	if plan.merged() {
+		u.doDelete(br)
+		return
+	}
+
+	question := fmt.Sprintf("%s is not merged into %s, %d commits would be lost: force delete? [y/n]",
+		br.Name, strings.Join(plan.against, " or "), plan.unmerged)
+	if len(plan.against) == 0 {
+		question = fmt.Sprintf("%s has no base branch nor upstream to check whether it is merged: force delete? [y/n]", br.Name)
+	}
+	u.ask(question, map[string]func(){
+		"y": func() { u.doDelete(br) },
+		"n": func() { u.status.SetText("delete aborted") },
+	})
+}
+
+func (u *tuiUI) doDelete(br *Branch) {
+	if err := u.inv.Delete(br); err != nil {
+		u.status.SetText(err.Error())
+		return
+	}
+	if err := u.refresh(); err != nil {
+		u.status.SetText(err.Error())
+		return
+	}
+	u.status.SetText(fmt.Sprintf("deleted %s (was %s)", br.Name, br.Commit.Hash.String()[0:7]))
+}
+
+// renameBranch asks for a new name for br and moves it there.
+func (u *tuiUI) renameBranch(br *Branch) {
+	if br == nil {
+		return
+	}
+	if br.Remote != "" {
+		u.status.SetText("remote-tracking branches cannot be renamed")
+		return
+	}
+	if br.isTag() {
+		u.status.SetText("tags cannot be renamed")
+		return
+	}
+	if u.isProtected(br) {
+		u.status.SetText(fmt.Sprintf("%s is protected and cannot be renamed", br.Name))
+		return
+	}
+	u.input("rename "+br.Name+" to:", br.Name, func(name string) {
+		renamed, err := u.inv.Rename(br, name)
+		if err != nil {
+			u.status.SetText(err.Error())
+			return
+		}
+		if u.base == br.Name {
+			u.base = renamed.Name
+		}
+		if err := u.refresh(); err != nil {
+			u.status.SetText(err.Error())
+			return
+		}
+		u.status.SetText(fmt.Sprintf("renamed %s to %s", br.Name, renamed.Name))
+	})
+}
+
+// newBranch asks for the name of a branch to create at the tip of from, and
+// then whether to track from and to switch to it.
+func (u *tuiUI) newBranch(from *Branch) {
+	if from == nil {
+		return
+	}
+	u.input("new branch from "+from.Name+":", "", func(name string) {
+		if err := checkRefName(name); err != nil {
+			u.status.SetText(err.Error())
+			return
+		}
+		create := func(track, switchTo bool) {
+			created, err := u.inv.Create(name, from, track)
+			if err != nil {
+				u.status.SetText(err.Error())
+				return
+			}
+			if err := u.refresh(); err != nil {
+				u.status.SetText(err.Error())
+				return
+			}
+			u.status.SetText(fmt.Sprintf("created %s from %s", name, from.Name))
+			if switchTo {
+				u.switchBranch(created)
+			}
+		}
+		u.ask(fmt.Sprintf("create %s: [c] just create it, [t] tracking %s, [s] and switch to it, [b] both?", name, from.Name), map[string]func(){
+			"c": func() { create(false, false) },
+			"t": func() { create(true, false) },
+			"s": func() { create(false, true) },
+			"b": func() { create(true, true) },
+		})
+	})
+}
+
+// newTag asks for the name and the message of an annotated tag to create at
+// the tip of from.
+func (u *tuiUI) newTag(from *Branch) {
+	if from == nil {
+		return
+	}
+	u.input("new tag at "+from.Name+":", "", func(name string) {
+		if err := checkRefName(name); err != nil {
+			u.status.SetText(err.Error())
+			return
+		}
+		u.input("message of "+name+":", "", func(msg string) {
+			if err := createTag(u.path, name, from, msg); err != nil {
+				u.status.SetText(err.Error())
+				return
+			}
+			u.showTags = true
+			if err := u.refresh(); err != nil {
+				u.status.SetText(err.Error())
+				return
+			}
+			u.status.SetText(fmt.Sprintf("tagged %s as %s", from.Name, name))
+		})
+	})
+}
+
+// pruneMerged shows the branches already merged into the base branch as a
+// checklist and deletes the ticked ones all at once.
+func (u *tuiUI) pruneMerged() {
+	merged, err := u.inv.MergedInto(u.brs, u.base, u.settings.protectedPatterns())
+	if err != nil {
+		u.status.SetText(err.Error())
+		return
+	}
+	if len(merged) == 0 {
+		u.status.SetText(fmt.Sprintf("no branches merged into %s to prune", u.base))
+		return
+	}
+
+	ticked := make([]bool, len(merged))
+	for i := range ticked {
+		ticked[i] = true
+	}
+	items := func() []string {
+		var lines []string
+		for i, br := range merged {
+			mark := "[ ]"
+			if ticked[i] {
+				mark = "[x]"
+			}
+			lines = append(lines, fmt.Sprintf("%s|%s|%s|%s", mark, br.Name, br.Commit.Hash.String()[0:7], br.Author.When.String()[2:19]))
+		}
+		return strings.Split(columnize.SimpleFormat(lines), "\n")
+	}
+
+	checklist := tui.NewList()
+	checklist.AddItems(items()...)
+	checklist.SetSelected(0)
+	box := tui.NewVBox(
+		tui.NewLabel(fmt.Sprintf("branches merged into %s, space to untick, enter to delete the ticked ones, esc to cancel", u.base)),
+		tui.NewLabel(""),
+		checklist,
+		tui.NewSpacer(),
+	)
+	box.SetBorder(true)
+
+	keys := u.keys
+	u.keys = make(map[string]func())
+	done := func() {
+		u.keys = keys
+		checklist.SetFocused(false)
+		u.refocus()
+		u.setWidget(u.root)
+	}
+	u.bind("Esc", done)
+	u.bind(u.settings.keys["quit"], done)
+	u.bind(" ", func() {
+		i := checklist.Selected()
+		ticked[i] = !ticked[i]
+		checklist.RemoveItems()
+		checklist.AddItems(items()...)
+		checklist.SetSelected(i)
+	})
+	checklist.OnItemActivated(func(*tui.List) {
+		var doomed []*Branch
+		for i, br := range merged {
+			if ticked[i] {
+				doomed = append(doomed, br)
+			}
+		}
+		done()
+		if len(doomed) == 0 {
+			u.status.SetText("nothing to prune")
+			return
+		}
+		if err := u.inv.DeleteAll(doomed); err != nil {
+			u.status.SetText(err.Error())
+			return
+		}
+		if err := u.refresh(); err != nil {
+			u.status.SetText(err.Error())
+			return
+		}
+		summary := fmt.Sprintf("removed %d branches merged into %s:\n\n", len(doomed), u.base)
+		for _, br := range doomed {
+			summary += fmt.Sprintf("    %s (was %s)\n", br.Name, br.Commit.Hash.String()[0:7])
+		}
+		u.diffView.SetText(summary)
+		u.status.SetText(fmt.Sprintf("pruned %d branches", len(doomed)))
+	})
+
+	u.blur()
+	checklist.SetFocused(true)
+	u.setWidget(tui.NewVBox(box, u.status))
+}
+
+// changeBase asks for the name of the branch to compare the others against.
+func (u *tuiUI) changeBase() {
+	u.input("base branch:", u.base, func(name string) {
+		if _, ok := u.brs[name]; !ok {
+			u.status.SetText(fmt.Sprintf("no %s branch", name))
+			return
+		}
+		u.base = name
+		u.inv.Compare(u.brs, u.brs[name])
+		u.inv.Compare(u.remotes, u.brs[name])
+		u.inv.Compare(u.tags, u.brs[name])
+		u.resort()
+		u.status.SetText("comparing against " + name)
+	})
+}
+
+// toggleDiffMode switches between diffing against the merge base with the
+// base branch, the default, and diffing against the base branch tip.
+func (u *tuiUI) toggleDiffMode() {
+	u.twoDot = !u.twoDot
+	if u.twoDot {
+		u.status.SetText("diffing against the tip of " + u.base)
+	} else {
+		u.status.SetText("diffing against the merge base with " + u.base)
+	}
+	if br := u.selected(); br != nil {
+		u.showChanges(br)
+	}
+}
+
+// showChanges fills the right pane for br in the background, after a
+// "computing…" placeholder.
+func (u *tuiUI) showChanges(br *Branch) {
+	u.diffShown = u.diffKey(br)
+	u.closePatch()
+	u.setChanges(nil)
+	u.setCommits(nil)
+	u.setStashes(nil)
+	u.setPatch(nil)
+	if u.pane == stashPane {
+		u.stopDiff()
+		u.showStashes(br)
+		return
+	}
+	fromBrName := u.base
+	if br.Ref == plumbing.ReferenceName("refs/heads/"+fromBrName) {
+		u.stopDiff()
+		u.diffView.SetText(tagDetails(br))
+		return
+	}
+	fromBr, ok := u.brs[fromBrName]
+	if !ok {
+		u.stopDiff()
+		u.diffView.SetText("no base branch to compare against, press b to choose one")
+		return
+	}
+
+	job := diffJob{
+		base:     fromBr.Name,
+		baseHash: fromBr.Commit.Hash,
+		br:       br.Name,
+		brHash:   br.Commit.Hash,
+		threeDot: !u.twoDot,
+	}
+	switch u.pane {
+	case logPane:
+		job.kind = logJob
+	case patchPane:
+		job.kind = patchJob
+	}
+	u.diffView.SetText("computing…")
+	u.computeDiff(job, func(res *diffResult) {
+		if res.err != nil {
+			u.diffView.SetText("")
+			u.status.SetText(res.err.Error())
+			return
+		}
+		if job.kind == logJob {
+			u.showLog(fromBr, br, res.commits)
+			return
+		}
+		u.showDiff(br, res)
+	})
+}
+
+func (u *tuiUI) showDiff(br *Branch, res *diffResult) {
+	changes, label := res.changes, res.label
+	if len(changes) == 0 {
+		u.setHeader(br, fmt.Sprintf("no changes between %s and %s", label, br.Name))
+		return
+	}
+	u.setHeader(br, fmt.Sprintf("changes against %s:\n", label))
+	if u.pane == filesPane {
+		// the list itself tells how many files it leaves out
+		u.setChanges(changes)
+		return
+	}
+	u.setPatch(res.lines)
+}
+
+// showLog lists the commits of br not in base.
+func (u *tuiUI) showLog(base, br *Branch, commits []*object.Commit) {
+	if len(commits) == 0 {
+		u.setHeader(br, fmt.Sprintf("no commits in %s that are not in %s", br.Name, base.Name))
+		return
+	}
+	u.setHeader(br, fmt.Sprintf("%d commits in %s..%s:\n", len(commits), base.Name, br.Name))
+	u.setCommits(commits)
+}
+
+// setHeader shows text above the right pane list, after the tag details if
+// br is an annotated tag.
+func (u *tuiUI) setHeader(br *Branch, text string) {
+	u.diffView.SetText(tagDetails(br) + text)
+}
+
+// computeDiff runs the job in the differ and fn with its result in the UI
+// goroutine, unless another job was started or the diff stopped meanwhile.
+func (u *tuiUI) computeDiff(job diffJob, fn func(*diffResult)) {
+	u.diffGen++
+	gen := u.diffGen
+	job.done = func(res *diffResult) {
+		u.Update(func() {
+			if gen == u.diffGen {
+				fn(res)
+			}
+		})
+	}
+	u.differ.submit(job)
+}
+
+// stopDiff drops the diff being computed, if any.
+func (u *tuiUI) stopDiff() {
+	u.diffGen++
+	u.differ.stop()
+}
+
+// showStashes lists the stashes, only the ones made on br with the filter.
+func (u *tuiUI) showStashes(br *Branch) {
+	stashes, err := readStashes(u.repo)
+	if err != nil {
+		u.diffView.SetText("")
+		u.status.SetText(err.Error())
+		return
+	}
+	all := len(stashes)
+	if u.stashFilter {
+		stashes = stashesOn(stashes, br.Name)
+	}
+	switch {
+	case all == 0:
+		u.diffView.SetText("no stashes")
+	case u.stashFilter:
+		u.diffView.SetText(fmt.Sprintf("%d of %d stashes made on %s:\n", len(stashes), all, br.Name))
+	default:
+		u.diffView.SetText(fmt.Sprintf("%d stashes:\n", all))
+	}
+	u.setStashes(stashes)
+}
+
+// toggleStashFilter switches between listing every stash and the ones made
+// on the selected branch.
+func (u *tuiUI) toggleStashFilter() {
+	u.stashFilter = !u.stashFilter
+	if u.pane != stashPane {
+		u.setPane(stashPane)
+	} else if br := u.selected(); br != nil {
+		u.showChanges(br)
+	}
+	if u.stashFilter {
+		u.status.SetText("showing the stashes made on the selected branch")
+	} else {
+		u.status.SetText("showing every stash")
+	}
+}
+
+// stashActions asks what to do with s.
+func (u *tuiUI) stashActions(s stash) {
+	run := func(cmd, done string) func() {
+		return func() {
+			if err := runStash(u.path, cmd, s); err != nil {
+				u.status.SetText(err.Error())
+				return
+			}
+			if br := u.selected(); br != nil {
+				u.showChanges(br)
+			}
+			u.status.SetText(fmt.Sprintf("%s %s", done, s.ref()))
+		}
+	}
+	u.ask(fmt.Sprintf("%s %s: [v]iew its diff, [a]pply, [p]op or [d]rop it?", s.ref(), s.Message), map[string]func(){
+		"v": func() { u.openStash(s) },
+		"a": run("apply", "applied"),
+		"p": run("pop", "popped"),
+		"d": run("drop", "dropped"),
+	})
+}
@@ -0,0 +1,70 @@
+package gitbr
+
+import (
+	"strings"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+const branchSection = "branch"
+
+// upstream returns the ref configured as upstream of the branch in the
+// branch.<name>.remote and branch.<name>.merge git config entries.
+func upstream(repo *git.Repository, name string) (plumbing.ReferenceName, bool) {
+	cfg, err := repo.Config()
+	if err != nil {
+		return "", false
+	}
+	return configUpstream(cfg, name)
+}
+
+// configUpstream is upstream with the git config already read.
+func configUpstream(cfg *config.Config, name string) (plumbing.ReferenceName, bool) {
+	s := cfg.Raw.Section(branchSection)
+	if !s.HasSubsection(name) {
+		return "", false
+	}
+	sub := s.Subsection(name)
+	remote, merge := sub.Option("remote"), sub.Option("merge")
+	if remote == "" || merge == "" {
+		return "", false
+	}
+	if remote == "." {
+		return plumbing.ReferenceName(merge), true
+	}
+	short := strings.TrimPrefix(merge, "refs/heads/")
+	return plumbing.ReferenceName("refs/remotes/" + remote + "/" + short), true
+}
+
+// upstreamHash resolves the upstream of the branch to a commit hash.
+func upstreamHash(repo *git.Repository, name string) (plumbing.Hash, bool) {
+	ref, ok := upstream(repo, name)
+	if !ok {
+		return plumbing.ZeroHash, false
+	}
+	return refHash(repo, ref)
+}
+
+// refHash resolves ref to a commit hash, reporting whether it exists.
+func refHash(repo *git.Repository, ref plumbing.ReferenceName) (plumbing.Hash, bool) {
+	resolved, err := repo.Reference(ref, true)
+	if err != nil {
+		return plumbing.ZeroHash, false
+	}
+	return resolved.Hash(), true
+}
+
+// setUpstream makes the ref merge of the given remote the upstream of the
+// branch, as git branch --set-upstream-to does.
+func setUpstream(repo *git.Repository, name, remote string, merge plumbing.ReferenceName) error {
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	cfg.Raw.Section(branchSection).Subsection(name).
+		SetOption("remote", remote).
+		SetOption("merge", merge.String())
+	return repo.Storer.SetConfig(cfg)
+}
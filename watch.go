@@ -0,0 +1,91 @@
+package gitbr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// pollInterval is how often the refs are checked when they cannot be
+	// watched.
+	pollInterval = 2 * time.Second
+	// watchDelay groups the changes a git command makes to several refs.
+	watchDelay = 200 * time.Millisecond
+)
+
+// watchRefs calls changed from a goroutine whenever the branches or HEAD of
+// the repository in gitDir change: something in refs/, packed-refs or HEAD.
+// It uses inotify where there is one and polls otherwise.
+func watchRefs(gitDir string, changed func()) {
+	events, err := notifyRefs(gitDir)
+	if err != nil {
+		events = pollRefs(gitDir, pollInterval)
+	}
+	go debounce(events, watchDelay, changed)
+}
+
+// debounce calls fn once the events stop coming for delay.
+func debounce(events <-chan struct{}, delay time.Duration, fn func()) {
+	for range events {
+		timer := time.NewTimer(delay)
+	wait:
+		for {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					break wait
+				}
+				timer.Reset(delay)
+			case <-timer.C:
+				break wait
+			}
+		}
+		timer.Stop()
+		fn()
+	}
+}
+
+// pollRefs sends an event whenever the stamp of the refs changes.
+func pollRefs(gitDir string, interval time.Duration) <-chan struct{} {
+	events := make(chan struct{}, 1)
+	go func() {
+		last := refsStamp(gitDir)
+		for range time.Tick(interval) {
+			if stamp := refsStamp(gitDir); stamp != last {
+				last = stamp
+				events <- struct{}{}
+			}
+		}
+	}()
+	return events
+}
+
+// refsStamp returns the names, sizes and modification times of the refs
+// files, which change whenever a ref does.
+func refsStamp(gitDir string) string {
+	var stamp strings.Builder
+	add := func(path string, info os.FileInfo) {
+		fmt.Fprintf(&stamp, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+	}
+	for _, name := range []string{"HEAD", "packed-refs"} {
+		if info, err := os.Stat(filepath.Join(gitDir, name)); err == nil {
+			add(name, info)
+		}
+	}
+	filepath.Walk(filepath.Join(gitDir, "refs"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && !isLockFile(path) {
+			add(path, info)
+		}
+		return nil
+	})
+	return stamp.String()
+}
+
+// isLockFile reports whether path is one of the files git writes a ref to
+// before renaming it into place.
+func isLockFile(path string) bool {
+	return strings.HasSuffix(path, ".lock")
+}
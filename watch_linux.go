@@ -0,0 +1,89 @@
+//go:build linux
+// +build linux
+
+package gitbr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_MOVED_TO | unix.IN_MOVED_FROM
+
+// notifyRefs sends an event whenever inotify reports a change in refs/,
+// packed-refs or HEAD, watching the directories created in refs/ as well.
+func notifyRefs(gitDir string) (<-chan struct{}, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	dirs := make(map[int]string)
+	watch := func(root string) error {
+		return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return err
+			}
+			wd, err := unix.InotifyAddWatch(fd, path, inotifyMask)
+			if err != nil {
+				return err
+			}
+			dirs[wd] = path
+			return nil
+		})
+	}
+	if _, err := unix.InotifyAddWatch(fd, gitDir, inotifyMask); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	if err := watch(filepath.Join(gitDir, "refs")); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	events := make(chan struct{}, 1)
+	go func() {
+		defer close(events)
+		var buf [unix.SizeofInotifyEvent * 256]byte
+		for {
+			n, err := unix.Read(fd, buf[:])
+			if err == unix.EINTR {
+				continue
+			}
+			if err != nil || n <= 0 {
+				return
+			}
+			changed := false
+			for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+				ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				start := offset + unix.SizeofInotifyEvent
+				name := strings.TrimRight(string(buf[start:start+int(ev.Len)]), "\x00")
+				offset = start + int(ev.Len)
+
+				dir, inRefs := dirs[int(ev.Wd)]
+				switch {
+				case isLockFile(name):
+				case !inRefs:
+					// the git directory itself, where only these matter
+					changed = changed || name == "HEAD" || name == "packed-refs"
+				case ev.Mask&unix.IN_ISDIR != 0 && ev.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+					// refs/heads/feature/ is created along with its first ref
+					watch(filepath.Join(dir, name))
+					changed = true
+				default:
+					changed = true
+				}
+			}
+			if changed {
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package gitbr
+
+import "errors"
+
+// notifyRefs is only implemented with inotify, the refs are polled elsewhere.
+func notifyRefs(gitDir string) (<-chan struct{}, error) {
+	return nil, errors.New("watching files is not supported")
+}
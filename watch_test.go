@@ -0,0 +1,62 @@
+package gitbr
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefsStamp(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	h := r.commit("initial", "README", "hello\n")
+	gitDir := filepath.Join(r.path, ".git")
+	stamp := refsStamp(gitDir)
+	assert.Equal(stamp, refsStamp(gitDir))
+	r.branch("feature/one", h)
+	assert.NotEqual(stamp, refsStamp(gitDir))
+}
+
+func TestWatchRefs(t *testing.T) {
+	assert := assert.New(t)
+	r := newTestRepo(t)
+	defer r.close()
+
+	h := r.commit("initial", "README", "hello\n")
+	changed := make(chan struct{}, 10)
+	watchRefs(filepath.Join(r.path, ".git"), func() { changed <- struct{}{} })
+
+	// a branch in a new directory of refs/heads
+	r.branch("feature/one", h)
+	select {
+	case <-changed:
+	case <-time.After(2 * pollInterval):
+		t.Fatal("the new branch was not noticed")
+	}
+	r.branch("feature/two", h)
+	select {
+	case <-changed:
+	case <-time.After(2 * pollInterval):
+		t.Fatal("the second branch was not noticed")
+	}
+	assert.Len(r.branches(), 3)
+}
+
+func TestDebounce(t *testing.T) {
+	assert := assert.New(t)
+	events := make(chan struct{})
+	calls := make(chan struct{}, 10)
+	go debounce(events, 20*time.Millisecond, func() { calls <- struct{}{} })
+
+	for i := 0; i < 5; i++ {
+		events <- struct{}{}
+	}
+	<-calls
+	close(events)
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(calls, "a burst of events is reported once")
+}